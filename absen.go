@@ -0,0 +1,185 @@
+// Package absen provides common interfaces over the time attendance and access control machine drivers,
+// so tools can work with SF3000, SF3500 and RAC2000 machines through one code path.
+//
+// Every adapter implements Device. Other interfaces are implemented by adapters whose machine supports them,
+// callers check them by type assertion and check finer features with Supports.
+//
+//	dev, err := absen.OpenSf3500(absen.Options{Address: "192.168.0.10"})
+//	if err != nil {
+//		return err
+//	}
+//	defer dev.Close()
+//	if source, ok := dev.(absen.LogSource); ok {
+//		punches, err := source.Punches()
+//	}
+package absen
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Returned by adapter methods the machine doesn't support
+var ErrNotSupported = errors.New("not supported by machine")
+
+// Default timeout of connecting and each command sent to machine
+const DefaultTimeout = 20 * time.Second
+
+// Connection options of machine, fields not used by a machine are ignored
+type Options struct {
+	Address     string        // host name or IP address, with optional port
+	MachineID   uint16        // machine number of SF3000 and RAC2000
+	Password    uint16        // communication password of SF3000
+	ProtocolKey uint32        // protocol key of SF3500, driver default when zero
+	Timeout     time.Duration // DefaultTimeout when zero
+	DeviceID    string        // device id of punches, machine serial number or id is used when empty
+}
+
+// Machine connection
+type Device interface {
+	// Driver name, sf3000, sf3500 or rac2000
+	Model() string
+	// Device id of punches
+	ID() string
+	Capabilities() Capability
+	Close() error
+}
+
+// Machine with clock
+type Clock interface {
+	Device
+	Time() (time.Time, error)
+	SetTime(t time.Time) error
+}
+
+// Measure the difference between machine clock and host clock, positive when machine clock is ahead.
+// The host time is taken at the middle of request round trip,
+// the result is only accurate to a second since machines don't report fraction of seconds.
+func TimeOffset(clock Clock) (time.Duration, error) {
+	begin := time.Now()
+	machineTime, err := clock.Time()
+	if err != nil {
+		return 0, err
+	}
+	hostTime := begin.Add(time.Since(begin) / 2)
+	return machineTime.Sub(hostTime.Truncate(time.Second)), nil
+}
+
+// Machine keeping attendance or access logs
+type LogSource interface {
+	Device
+	// Read logs kept by machine. Machines removing logs once they are read, such as RAC2000, don't return them again
+	// after a successful call, see adapter documentation. Log sources marking logs read don't mark them in Punches,
+	// use PunchesAndMark of MarkingLogSource.
+	Punches() ([]Punch, error)
+}
+
+// Log source marking logs read only after they are handled, so logs aren't lost when handling fails
+type MarkingLogSource interface {
+	LogSource
+	// Pass unread logs to handle and mark them read after handle returns nil.
+	// Returns number of handled logs.
+	PunchesAndMark(handle func(punches []Punch) error) (int, error)
+}
+
+// Machine keeping enrolled users
+type UserStore interface {
+	Device
+	Users() ([]User, error)
+	SetUsers(users ...User) error
+	DeleteUsers(ids ...string) error
+}
+
+// User store reading users by id with all of their data, including data not read by Users
+type UserReader interface {
+	UserStore
+	UsersByID(ids ...string) ([]User, error)
+}
+
+// Check whether device declares all of the capabilities
+func Supports(device Device, capability Capability) bool {
+	return device.Capabilities().Has(capability)
+}
+
+// Features declared by adapters
+type Capability uint32
+
+const (
+	CapClock Capability = 1 << iota
+	CapLogs
+	CapUsers
+	CapDeleteUsers
+	CapFingerprint
+	CapCard
+	CapFace
+	CapPalm
+	CapPassword
+	CapPhoto
+	CapDoor
+)
+
+var capabilityNames = []struct {
+	capability Capability
+	name       string
+}{
+	{CapClock, "clock"},
+	{CapLogs, "logs"},
+	{CapUsers, "users"},
+	{CapDeleteUsers, "delete_users"},
+	{CapFingerprint, "fingerprint"},
+	{CapCard, "card"},
+	{CapFace, "face"},
+	{CapPalm, "palm"},
+	{CapPassword, "password"},
+	{CapPhoto, "photo"},
+	{CapDoor, "door"},
+}
+
+// Check whether all of the capabilities are included
+func (c Capability) Has(capability Capability) bool {
+	return c&capability == capability
+}
+
+// Names of included capabilities
+func (c Capability) Names() []string {
+	names := make([]string, 0)
+	for _, item := range capabilityNames {
+		if c.Has(item.capability) {
+			names = append(names, item.name)
+		}
+	}
+	return names
+}
+
+func (c Capability) String() string {
+	return strings.Join(c.Names(), ",")
+}
+
+// Add default port when address has no port
+func AddressWithPort(address string, port int) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+func (options Options) timeout() time.Duration {
+	if options.Timeout > 0 {
+		return options.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Convert (bool, error) result of drivers
+func DriverError(ok bool, err error, operation string) error {
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(operation + " rejected by machine")
+	}
+	return nil
+}
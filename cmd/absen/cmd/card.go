@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/rac2000"
+	"github.com/spf13/cobra"
+)
+
+// represents the card command
+var cardCommand = &cobra.Command{
+	Use:         "card",
+	Short:       "Card management command",
+	Annotations: forModels("rac2000")}
+var cardListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "Get registered cards list from machine",
+	Args:  cobra.ExactArgs(0),
+	RunE:  getCards}
+var cardAddCommand = &cobra.Command{
+	Use:     "add",
+	Short:   "Register new card to machine",
+	Example: "absen --model rac2000 card add --host 192.168.0.1 --card-facility-code 186 --card-id 45123",
+	Args:    cobra.ExactArgs(0),
+	RunE:    addCard}
+var cardDelCommand = &cobra.Command{
+	Use:   "del",
+	Short: "Remove card from machine",
+	Args:  cobra.ExactArgs(0),
+	RunE:  delCard}
+
+var (
+	cardFacilityCode uint8
+	cardId           uint16
+	cardPassword     uint32
+	cardTimezone     uint8
+	cardStatus       uint8
+)
+
+func init() {
+	cardCommand.AddCommand(cardListCommand)
+	cardAddCommand.Flags().Uint16VarP(&cardId, "card-id", "i", 0, "Card ID")
+	cardAddCommand.Flags().Uint32VarP(&cardPassword, "card-password", "w", 0, "Card password")
+	cardAddCommand.Flags().Uint8VarP(&cardTimezone, "card-timezone", "t", 0, "Card timezone")
+	cardAddCommand.Flags().Uint8VarP(&cardStatus, "card-status", "s", 0, "Card status")
+	cardCommand.AddCommand(cardAddCommand)
+	cardDelCommand.Flags().Uint16VarP(&cardId, "card-id", "i", 0, "Card ID")
+	cardCommand.AddCommand(cardDelCommand)
+
+	RootCmd.AddCommand(cardCommand)
+}
+
+// Shorthand of --card-facility-code, -f in RAC2000 binary
+var cardFacilityShorthand string
+
+// Bind --card-facility-code of card add and del. When it takes -f, output format of these commands
+// is given by --output-format only.
+func bindCardFacilityFlags() {
+	for _, cmd := range []*cobra.Command{cardAddCommand, cardDelCommand} {
+		if cardFacilityShorthand == "f" {
+			cmd.Flags().StringVar(&outputFormat, "output-format", "json", "Available format: "+strings.Join(outputFormats, ", "))
+		}
+		cmd.Flags().Uint8VarP(&cardFacilityCode, "card-facility-code", cardFacilityShorthand, 0, "Card facility code")
+	}
+}
+
+// Connect to RAC2000 machine
+func connectRac2000() (*rac2000.Rac2000, error) {
+	device, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	if rac, ok := device.(*absen.Rac2000Device); ok {
+		return rac.Driver(), nil
+	}
+	device.Close()
+	return nil, usageErrorf("command is available for rac2000 only")
+}
+
+// Card facility code and id are required
+func checkCardFlags(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("card-facility-code") || !cmd.Flags().Changed("card-id") {
+		return usageErrorf("--card-facility-code and --card-id are required")
+	}
+	return nil
+}
+
+func getCards(cmd *cobra.Command, args []string) error {
+	device, err := connectRac2000()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	list, err := device.GetCards()
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(list))
+	for i, card := range list {
+		rows = append(rows, []string{strconv.Itoa(i + 1), strconv.Itoa(int(card.FacilityCode)), strconv.Itoa(int(card.Id)), strconv.Itoa(int(card.Password)), strconv.Itoa(int(card.Timezone)), strconv.Itoa(int(card.Status))})
+	}
+	return render(list, []string{"No", "Facility Code", "Card ID", "Password", "Timezone", "Status"}, rows)
+}
+
+func addCard(cmd *cobra.Command, args []string) error {
+	if err := checkCardFlags(cmd); err != nil {
+		return err
+	}
+	device, err := connectRac2000()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	ok, err := device.AddCard(rac2000.Card{
+		FacilityCode: cardFacilityCode,
+		Id:           cardId,
+		Password:     cardPassword,
+		Timezone:     cardTimezone,
+		Status:       cardStatus})
+	return absen.DriverError(ok, err, "add card")
+}
+
+func delCard(cmd *cobra.Command, args []string) error {
+	if err := checkCardFlags(cmd); err != nil {
+		return err
+	}
+	device, err := connectRac2000()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	ok, err := device.DelCard(cardFacilityCode, cardId)
+	return absen.DriverError(ok, err, "delete card")
+}
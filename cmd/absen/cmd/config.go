@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Prefix of environment variables overriding flags, such as ABSEN_HOST
+const envPrefix = "ABSEN"
+
+var (
+	configFile string
+	deviceName string
+)
+
+// Settings allowed in device profile, named after the flags they set, and tags to select devices by --devices
+var profileKeys = []string{"model", "host", "port", "nid", "password", "timeout", "timezone", "tags"}
+
+func init() {
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Configuration file, default is "+config.DefaultFile())
+	RootCmd.PersistentFlags().StringVar(&deviceName, "device", "", "Connect to the named device of configuration file")
+}
+
+// initConfig sets flags not given in command line from ABSEN_* environment variables
+// and then from the device profile selected by --device.
+func initConfig(cmd *cobra.Command) error {
+	flags := cmd.Root().PersistentFlags()
+	// global flag shadowed by a flag of the command, such as --output-format of RAC2000 card add, is given when that flag is
+	cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if global := flags.Lookup(flag.Name); global != nil && flag.Changed {
+			global.Changed = true
+		}
+	})
+	if err := applyConfig(flags); err != nil {
+		return err
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return usageErrorf("invalid time zone %q: %v", timezone, err)
+		}
+		// drivers read and write machine clock in local time
+		time.Local = location
+	}
+	return nil
+}
+
+// Set flags from environment variables and the device profile selected by --device, which may be given by ABSEN_DEVICE.
+// Environment variables win over the profile, as they do for every command running on a device of --devices.
+func applyConfig(flags *pflag.FlagSet) error {
+	if err := config.Apply(flags, envPrefix, nil); err != nil {
+		return usageError{err}
+	}
+	name := flags.Lookup("device").Value.String()
+	if name == "" {
+		return nil
+	}
+	profile, err := deviceProfile(name)
+	if err != nil {
+		return err
+	}
+	if flags.Changed("model") {
+		if value, ok := profile["model"]; ok {
+			driver, ok := absen.Lookup(fmt.Sprint(value))
+			given, known := absen.Lookup(flags.Lookup("model").Value.String())
+			if ok && known && driver.Model != given.Model {
+				return usageErrorf("device %s is %s machine, not %s", name, driver.Model, given.Model)
+			}
+		}
+	}
+	if err := config.Apply(flags, envPrefix, profile); err != nil {
+		return usageErrorf("device %s: %v", name, err)
+	}
+	return nil
+}
+
+// Read configuration file given by --config or the default file
+func readConfig() (*viper.Viper, error) {
+	v, err := config.Read(configFile)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	return v, nil
+}
+
+// Named device profile in devices section of configuration file
+func deviceProfile(name string) (map[string]interface{}, error) {
+	v, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	devices := config.Section(v, "devices")
+	// keys of configuration are case insensitive
+	profile, ok := devices[strings.ToLower(name)].(map[string]interface{})
+	if !ok {
+		if len(devices) == 0 {
+			return nil, usageErrorf("device %s is not found, no devices in configuration file", name)
+		}
+		return nil, usageErrorf("device %s is not found, use %s", name, strings.Join(sortedKeys(devices), ", "))
+	}
+	if err := checkProfile(name, profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Check device profile has known settings only
+func checkProfile(name string, profile map[string]interface{}) error {
+	for key := range profile {
+		if !contains(profileKeys, key) {
+			return usageErrorf("unknown setting %q of device %s, use %s", key, name, strings.Join(profileKeys, ", "))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(settings map[string]interface{}) []string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
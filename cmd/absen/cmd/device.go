@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/spf13/cobra"
+)
+
+var modelsCommand = &cobra.Command{
+	Use:   "models",
+	Short: "List supported machine models",
+	Args:  cobra.ExactArgs(0),
+	RunE:  listModels}
+
+func init() {
+	RootCmd.AddCommand(modelsCommand)
+}
+
+// Connection options from flags
+func connectOptions(address string) (absen.Options, error) {
+	if model == "" {
+		return absen.Options{}, usageErrorf("--model is required, use %s", strings.Join(absen.Models(), ", "))
+	}
+	if address == "" {
+		return absen.Options{}, usageErrorf("--host is required")
+	}
+	if port != 0 {
+		address = absen.AddressWithPort(address, port)
+	}
+	return absen.Options{Address: address, MachineID: nid, Password: password, Timeout: timeout}, nil
+}
+
+// Connect to machine specified by flags
+func connect() (absen.Device, error) {
+	return connectTo(host)
+}
+
+// Connect to machine at address with model and parameters specified by flags
+func connectTo(address string) (absen.Device, error) {
+	options, err := connectOptions(address)
+	if err != nil {
+		return nil, err
+	}
+	return absen.Open(model, options)
+}
+
+// Connect to SF3500 machine, for commands available for sf3500 only
+func connectSf3500() (*absen.Sf3500Device, *sf3500.Sf3500, error) {
+	device, err := connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	if sf, ok := device.(*absen.Sf3500Device); ok {
+		return sf, sf.Driver(), nil
+	}
+	device.Close()
+	return nil, nil, usageErrorf("command is available for sf3500 only")
+}
+
+// Error of feature the machine doesn't support
+func unsupported(device absen.Device, feature string) error {
+	return usageErrorf("%s of %s: %v", feature, device.Model(), absen.ErrNotSupported)
+}
+
+// Registered driver in json output
+type modelInfo struct {
+	Model       string        `json:"model"`
+	Description string        `json:"description"`
+	DefaultPort int           `json:"defaultPort"`
+	Params      []absen.Param `json:"params"`
+}
+
+func listModels(cmd *cobra.Command, args []string) error {
+	drivers := absen.Drivers()
+	list := make([]modelInfo, 0, len(drivers))
+	rows := make([][]string, 0, len(drivers))
+	for _, driver := range drivers {
+		list = append(list, modelInfo{driver.Model, driver.Description, driver.DefaultPort, driver.Params})
+		params := make([]string, 0, len(driver.Params))
+		for _, param := range driver.Params {
+			params = append(params, string(param))
+		}
+		rows = append(rows, []string{driver.Model, driver.Description, strconv.Itoa(driver.DefaultPort), strings.Join(params, ", ")})
+	}
+	return render(list, []string{"Model", "Description", "Default Port", "Parameters"}, rows)
+}
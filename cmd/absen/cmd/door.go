@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/spf13/cobra"
+)
+
+// represents the door command
+var doorCommand = &cobra.Command{
+	Use:         "door",
+	Short:       "Control door",
+	Long:        "Open, lock, and return door to normal mode remotely",
+	Annotations: forModels("sf3500")}
+
+var doorKeepOpen bool
+
+type doorResult struct {
+	Host    string `json:"host"`
+	Status  string `json:"status"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func init() {
+	doorOpenCommand := &cobra.Command{
+		Use:     "open",
+		Short:   "Open door once or keep it open",
+		Example: "To open door once:\n\tabsen --model sf3500 door open\nTo keep door open until returned to normal:\n\tabsen --model sf3500 door open --keep",
+		Args:    cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if doorKeepOpen {
+				return setDoorStatus(sf3500.DoorKeepOpen)
+			}
+			return setDoorStatus(sf3500.DoorOpen)
+		}}
+	doorOpenCommand.Flags().BoolVar(&doorKeepOpen, "keep", false, "Keep door open until returned to normal")
+	doorCommand.AddCommand(doorOpenCommand)
+	doorCommand.AddCommand(&cobra.Command{
+		Use:   "lock",
+		Short: "Keep door closed until returned to normal",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDoorStatus(sf3500.DoorKeepClosed)
+		}})
+	doorCommand.AddCommand(&cobra.Command{
+		Use:   "normal",
+		Short: "Return door to normal mode",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDoorStatus(sf3500.DoorNormal)
+		}})
+
+	RootCmd.AddCommand(doorCommand)
+}
+
+// Change door status and print the result
+func setDoorStatus(status sf3500.DoorStatus) error {
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	result := doorResult{Host: host, Status: string(status)}
+	result.Success, err = device.SetDoorStatus(status)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	device.Close()
+	rows := [][]string{{result.Host, result.Status, strconv.FormatBool(result.Success), result.Error}}
+	if err := render(result, []string{"Host", "Status", "Success", "Error"}, rows); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("change door status failed")
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	fleetDevices     []string
+	fleetConcurrency int
+	fleetTimeout     time.Duration
+)
+
+// Flags of fan-out, they are not passed to the command running on each device
+var fleetFlags = []string{"devices", "concurrency", "device-timeout"}
+
+// Output flags of fan-out result. They are passed to the command on each device only when it writes its output
+// to a file of the device, otherwise output of the command is kept in json format in the result.
+var fleetOutputFlags = []string{"output-format", "output-file", "columns", "template"}
+
+// Placeholder of device name in --output-file, each device writes its output to its own file
+const devicePlaceholder = "{device}"
+
+func init() {
+	RootCmd.PersistentFlags().StringSliceVar(&fleetDevices, "devices", nil, "Run the command on these devices of configuration file, by device name, group, tag or all, separated by comma")
+	RootCmd.PersistentFlags().IntVar(&fleetConcurrency, "concurrency", 4, "Maximum number of devices the command runs on at the same time with --devices")
+	RootCmd.PersistentFlags().DurationVar(&fleetTimeout, "device-timeout", 5*time.Minute, "Command on a device is stopped when it takes longer with --devices")
+}
+
+// Result of command on a device
+type fleetResult struct {
+	Device     string          `json:"device"`
+	Model      string          `json:"model"`
+	Host       string          `json:"host"`
+	Success    bool            `json:"success"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+	Output     json.RawMessage `json:"output,omitempty"`
+}
+
+// Selected device of fan-out
+type fleetDevice struct {
+	name    string
+	profile map[string]interface{}
+}
+
+// Run commands on selected devices when --devices is given
+func fleetCommands(cmd *cobra.Command) {
+	for _, child := range cmd.Commands() {
+		fleetCommands(child)
+	}
+	if cmd.RunE == nil || cmd == modelsCommand {
+		return
+	}
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(fleetDevices) == 0 {
+			return run(cmd, args)
+		}
+		return runFleet(cmd)
+	}
+}
+
+// Run the command on each selected device as a separate process and render the results
+func runFleet(cmd *cobra.Command) error {
+	// environment variables win over device profiles, so they would connect every command to the same machine
+	flags := cmd.Flags()
+	for _, name := range []string{"device", "host", "port"} {
+		if flags.Changed(name) {
+			return usageErrorf("--%s and %s can't be used with --devices", name, config.EnvName(envPrefix, name))
+		}
+	}
+	devices, err := selectDevices(fleetDevices, commandModels(cmd))
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	perDevice := strings.Contains(outputFile, devicePlaceholder)
+	if cmd == logFetchCommand && logMarkRead && !perDevice {
+		return usageErrorf("--mark-read with --devices requires --output-file with %s, e.g. -o logs-%s.json", devicePlaceholder, devicePlaceholder)
+	}
+	strip := append([]string{}, fleetFlags...)
+	if perDevice {
+		strip = append(strip, "output-file")
+	} else {
+		strip = append(strip, fleetOutputFlags...)
+	}
+	args := fleetArgs(cmd.Flags(), os.Args[1:], strip)
+	if fleetConcurrency < 1 {
+		fleetConcurrency = 1
+	}
+	results := make([]fleetResult, len(devices))
+	workers := make(chan struct{}, fleetConcurrency)
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int, device fleetDevice) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			results[i] = runOnDevice(executable, args, device, perDevice)
+		}(i, device)
+	}
+	wg.Wait()
+
+	failed := 0
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+		duration := time.Duration(result.DurationMs) * time.Millisecond
+		rows = append(rows, []string{result.Device, result.Model, result.Host, strconv.FormatBool(result.Success), result.Error, duration.String()})
+	}
+	if perDevice {
+		// output of devices is in their files, result is written to standard output without columns and template of the files
+		outputFile, outputColumns = "", nil
+		if outputFormat == "template" {
+			outputFormat = "json"
+		}
+	}
+	if err := render(results, []string{"Device", "Model", "Host", "Success", "Error", "Duration"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("command failed on %d of %d devices", failed, len(results))
+	}
+	return nil
+}
+
+// Run the command on a device, output of the command is kept in the result or written to output file of the device
+func runOnDevice(executable string, args []string, device fleetDevice, perDevice bool) fleetResult {
+	result := fleetResult{Device: device.name, Model: profileString(device.profile, "model"), Host: profileString(device.profile, "host")}
+	ctx, cancel := context.WithTimeout(context.Background(), fleetTimeout)
+	defer cancel()
+	deviceArgs := []string{"--device=" + device.name, "--output-format=json"}
+	if perDevice {
+		deviceArgs = []string{"--device=" + device.name, "--output-file=" + strings.ReplaceAll(outputFile, devicePlaceholder, device.name)}
+	}
+	command := exec.CommandContext(ctx, executable, append(deviceArgs, args...)...)
+	command.Env = fleetEnv(os.Environ(), perDevice)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	begin := time.Now()
+	err := command.Run()
+	result.DurationMs = time.Since(begin).Milliseconds()
+	if output := bytes.TrimSpace(stdout.Bytes()); len(output) > 0 {
+		if json.Valid(output) {
+			result.Output = output
+		} else {
+			result.Output, _ = json.Marshal(string(output))
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Error = fmt.Sprintf("timeout after %v", fleetTimeout)
+	} else if err != nil {
+		result.Error = commandError(stderr.String(), err)
+	} else {
+		result.Success = true
+	}
+	return result
+}
+
+// Environment of the command on a device. Fan-out is not repeated by the command and output flags are not taken
+// from environment when output of the command is kept in the result.
+// Other environment variables are passed, they win over the device profile as they do with --device.
+func fleetEnv(environ []string, perDevice bool) []string {
+	names := append([]string{}, fleetFlags...)
+	if !perDevice {
+		names = append(names, fleetOutputFlags...)
+	}
+	env := append([]string{}, environ...)
+	for _, name := range names {
+		env = append(env, config.EnvName(envPrefix, name)+"=")
+	}
+	return env
+}
+
+// Error message printed by the command, or error of running the command when nothing printed
+func commandError(stderr string, err error) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, "Error: ") {
+			return strings.TrimPrefix(line, "Error: ")
+		}
+	}
+	if message := strings.TrimSpace(stderr); message != "" {
+		return message
+	}
+	return err.Error()
+}
+
+// Command line arguments without the flags to strip, shorthands are looked up in flags of the command
+func fleetArgs(flags *pflag.FlagSet, args []string, strip []string) []string {
+	list := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(list, args[i:]...)
+		}
+		name, hasValue := fleetFlag(flags, arg, strip)
+		if name == "" {
+			list = append(list, arg)
+			continue
+		}
+		if !hasValue {
+			// value is the next argument
+			i++
+		}
+	}
+	return list
+}
+
+// Name of the flag to strip in the argument and whether the argument has the value, empty name for other arguments
+func fleetFlag(flags *pflag.FlagSet, arg string, strip []string) (string, bool) {
+	for _, name := range strip {
+		if arg == "--"+name {
+			return name, false
+		}
+		if strings.HasPrefix(arg, "--"+name+"=") {
+			return name, true
+		}
+	}
+	// shorthand such as -f json or -fjson, -f is card facility code of card commands in RAC2000 binary
+	if len(arg) < 2 || arg[0] != '-' || arg[1] == '-' {
+		return "", false
+	}
+	flag := flags.ShorthandLookup(arg[1:2])
+	if flag == nil || !contains(strip, flag.Name) {
+		return "", false
+	}
+	return flag.Name, len(arg) > 2
+}
+
+// Devices selected by device names, groups, tags or all, limited to the models the command is available for
+// and the model given by --model
+func selectDevices(selectors []string, models []string) ([]fleetDevice, error) {
+	v, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	devices := config.Section(v, "devices")
+	if len(devices) == 0 {
+		return nil, usageErrorf("no devices in configuration file")
+	}
+	groups := config.Section(v, "groups")
+	names := sortedKeys(devices)
+	selected := make(map[string]bool)
+	for _, selector := range selectors {
+		key := strings.ToLower(strings.TrimSpace(selector))
+		switch {
+		case key == "all":
+			for _, name := range names {
+				selected[name] = true
+			}
+		case devices[key] != nil:
+			selected[key] = true
+		case groups[key] != nil:
+			members, ok := groups[key].([]interface{})
+			if !ok {
+				return nil, usageErrorf("group %s must be a list of devices", selector)
+			}
+			for _, member := range members {
+				name := strings.ToLower(fmt.Sprint(member))
+				if devices[name] == nil {
+					return nil, usageErrorf("device %s of group %s is not found", member, selector)
+				}
+				selected[name] = true
+			}
+		default:
+			found := false
+			for _, name := range names {
+				if profile, ok := devices[name].(map[string]interface{}); ok && hasTag(profile, key) {
+					selected[name] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, usageErrorf("%s is not a device, group or tag of configuration file", selector)
+			}
+		}
+	}
+	list := make([]fleetDevice, 0, len(selected))
+	for _, name := range names {
+		if !selected[name] {
+			continue
+		}
+		profile, ok := devices[name].(map[string]interface{})
+		if !ok {
+			return nil, usageErrorf("device %s must have settings", name)
+		}
+		if err := checkProfile(name, profile); err != nil {
+			return nil, err
+		}
+		deviceModel := profileString(profile, "model")
+		if driver, ok := absen.Lookup(deviceModel); ok {
+			deviceModel = driver.Model
+		}
+		if (model != "" && deviceModel != model) || (len(models) > 0 && !contains(models, deviceModel)) {
+			continue
+		}
+		list = append(list, fleetDevice{name: name, profile: profile})
+	}
+	if len(list) == 0 {
+		if model != "" {
+			return nil, usageErrorf("no %s devices are selected", model)
+		}
+		return nil, usageErrorf("no devices are selected, command is available for %s", strings.Join(models, ", "))
+	}
+	return list, nil
+}
+
+// Device profile has the tag, tags are case insensitive
+func hasTag(profile map[string]interface{}, tag string) bool {
+	tags, _ := profile["tags"].([]interface{})
+	for _, item := range tags {
+		if strings.ToLower(fmt.Sprint(item)) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func profileString(profile map[string]interface{}, key string) string {
+	if value, ok := profile[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestFleetArgs(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringSlice("devices", nil, "")
+	flags.Int("concurrency", 4, "")
+	flags.StringP("output-format", "f", "json", "")
+	flags.StringP("output-file", "o", "", "")
+	flags.StringSlice("columns", nil, "")
+	flags.Bool("new-only", false, "")
+	// -f of card add in RAC2000 binary
+	cardFlags := pflag.NewFlagSet("card", pflag.ContinueOnError)
+	cardFlags.StringSlice("devices", nil, "")
+	cardFlags.String("output-format", "json", "")
+	cardFlags.Uint8P("card-facility-code", "f", 0, "")
+	cardFlags.Uint16P("card-id", "i", 0, "")
+
+	strip := append(append([]string{}, fleetFlags...), fleetOutputFlags...)
+	tests := []struct {
+		name  string
+		flags *pflag.FlagSet
+		args  []string
+		strip []string
+		want  []string
+	}{
+		{"separate values", flags, []string{"log", "fetch", "--devices", "all", "--concurrency", "8", "--new-only"}, strip,
+			[]string{"log", "fetch", "--new-only"}},
+		{"joined values", flags, []string{"--devices=office", "user", "count", "--output-format=table", "--columns=device"}, strip,
+			[]string{"user", "count"}},
+		{"shorthands", flags, []string{"log", "fetch", "-f", "csv", "-ologs.json", "--devices", "all"}, strip,
+			[]string{"log", "fetch"}},
+		{"output kept for file of each device", flags, []string{"log", "fetch", "-f", "csv", "-o", "logs-{device}.csv", "--devices", "all"},
+			append(append([]string{}, fleetFlags...), "output-file"),
+			[]string{"log", "fetch", "-f", "csv"}},
+		{"arguments after --", flags, []string{"--devices", "all", "user", "del", "--", "--devices"}, strip,
+			[]string{"user", "del", "--", "--devices"}},
+		{"shorthand of other flag", cardFlags, []string{"card", "add", "-f", "12", "-i", "5", "--devices", "gate", "--output-format", "table"}, strip,
+			[]string{"card", "add", "-f", "12", "-i", "5"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if args := fleetArgs(test.flags, test.args, test.strip); !reflect.DeepEqual(args, test.want) {
+				t.Errorf("args = %q, want %q", args, test.want)
+			}
+		})
+	}
+}
+
+func TestFleetEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "ABSEN_HOST=10.0.0.9"}
+	env := fleetEnv(environ, false)
+	want := []string{"PATH=/bin", "ABSEN_HOST=10.0.0.9", "ABSEN_DEVICES=", "ABSEN_CONCURRENCY=", "ABSEN_DEVICE_TIMEOUT=",
+		"ABSEN_OUTPUT_FORMAT=", "ABSEN_OUTPUT_FILE=", "ABSEN_COLUMNS=", "ABSEN_TEMPLATE="}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %q, want %q", env, want)
+	}
+	// output flags apply to the file of each device
+	env = fleetEnv(environ, true)
+	for _, item := range env {
+		if strings.HasPrefix(item, "ABSEN_OUTPUT_") || strings.HasPrefix(item, "ABSEN_COLUMNS") {
+			t.Errorf("env has %s for command writing output file", item)
+		}
+	}
+}
+
+// Flags resolved by configuration, as the global flags of root command
+func newConfigFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("model", "", "")
+	flags.String("device", "", "")
+	flags.String("host", "", "")
+	flags.Int("port", 0, "")
+	return flags
+}
+
+func TestDeviceAndDevicesResolveSameSettings(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	data := "devices:\n  lobby:\n    model: sf3500\n    host: 10.0.0.1\n    port: 5005\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(file string) { configFile = file }(configFile)
+	configFile = file
+	t.Setenv("ABSEN_HOST", "127.0.0.1")
+
+	// absen --device lobby
+	flags := newConfigFlags()
+	if err := flags.Parse([]string{"--device", "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(flags); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := flags.GetString("host")
+	port, _ := flags.GetInt("port")
+
+	// command on lobby device of absen --devices lobby, in environment of the command
+	for _, item := range fleetEnv(os.Environ(), false) {
+		pair := strings.SplitN(item, "=", 2)
+		t.Setenv(pair[0], pair[1])
+	}
+	deviceFlags := newConfigFlags()
+	if err := deviceFlags.Parse([]string{"--device=lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(deviceFlags); err != nil {
+		t.Fatal(err)
+	}
+	fleetHost, _ := deviceFlags.GetString("host")
+	fleetPort, _ := deviceFlags.GetInt("port")
+
+	if host != "127.0.0.1" || port != 5005 {
+		t.Errorf("--device resolved %s:%d, want environment host and profile port 127.0.0.1:5005", host, port)
+	}
+	if fleetHost != host || fleetPort != port {
+		t.Errorf("--devices resolved %s:%d, --device resolved %s:%d", fleetHost, fleetPort, host, port)
+	}
+}
+
+func TestSelectDevices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	data := `groups:
+  office: [lobby, gate]
+  broken: [lobby, cellar]
+devices:
+  Lobby:
+    model: sf3500
+    host: 10.0.0.1
+    tags: [floor1]
+  lobby2:
+    model: sf3000
+    host: 10.0.0.2
+    tags: [Floor1]
+  gate:
+    model: RAC2000
+    host: 10.0.0.3
+`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(file string, name string) { configFile, model = file, name }(configFile, model)
+	configFile = file
+
+	tests := []struct {
+		name      string
+		selectors []string
+		model     string
+		models    []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "all", selectors: []string{"all"}, want: []string{"gate", "lobby", "lobby2"}},
+		{name: "device names are case insensitive", selectors: []string{"LOBBY"}, want: []string{"lobby"}},
+		{name: "group", selectors: []string{"office"}, want: []string{"gate", "lobby"}},
+		{name: "tag", selectors: []string{"floor1"}, want: []string{"lobby", "lobby2"}},
+		{name: "selected once", selectors: []string{"lobby", "floor1", "office"}, want: []string{"gate", "lobby", "lobby2"}},
+		{name: "model", selectors: []string{"all"}, model: "rac2000", want: []string{"gate"}},
+		{name: "models of command", selectors: []string{"all"}, models: []string{"sf3000", "sf3500"}, want: []string{"lobby", "lobby2"}},
+		{name: "unknown selector", selectors: []string{"cellar"}, wantErr: true},
+		{name: "unknown device of group", selectors: []string{"broken"}, wantErr: true},
+		{name: "no device of model", selectors: []string{"office"}, model: "sf3000", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model = test.model
+			devices, err := selectDevices(test.selectors, test.models)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			names := make([]string, 0, len(devices))
+			for _, device := range devices {
+				names = append(names, device.name)
+			}
+			if !test.wantErr && !reflect.DeepEqual(names, test.want) {
+				t.Errorf("devices = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+	"github.com/spf13/cobra"
+)
+
+// represents the log command
+var logCommand = &cobra.Command{
+	Use:   "log",
+	Short: "Log data command"}
+var logFetchCommand = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch log data from machine",
+	Long: "Fetch log data from machine. SF3500 keeps logs and they are fetched by date, " +
+		"SF3000 logs are kept on the machine and RAC2000 logs are removed from the machine once fetched.",
+	Example: "To fetch all logs of January 2022:\n\tabsen --model sf3500 log fetch --since 2022-01-01 --until 2022-01-31\n" +
+		"To fetch unread logs, save them to file and mark them as read afterward:\n\tabsen --model sf3500 log fetch --new-only --mark-read -o logs.json\n" +
+		"To append unread logs to file as they arrive and mark them as read:\n\tabsen --model sf3500 log fetch --new-only --mark-read -f ndjson -o logs.ndjson",
+	Args: cobra.ExactArgs(0),
+	RunE: fetchLog}
+
+var (
+	logRaw      bool
+	logNewOnly  bool
+	logMarkRead bool
+	logSince    string
+	logUntil    string
+	photoDir    string
+)
+
+// Flags of log fetch command available for sf3500 only
+var sf3500LogFlags = []string{"new-only", "mark-read", "since", "until", "photo-dir"}
+
+func init() {
+	logFetchCommand.Flags().BoolVar(&logRaw, "raw", false, "Write logs in json format of the machine instead of normalized punches")
+	logFetchCommand.Flags().BoolVar(&logNewOnly, "new-only", false, "Fetch unread logs only, sf3500 only")
+	logFetchCommand.Flags().BoolVar(&logMarkRead, "mark-read", false, "Mark fetched logs as read after they are written to output file, sf3500 only")
+	logFetchCommand.Flags().StringVar(&logSince, "since", "", "Fetch logs from this date, format: 2006-01-02, sf3500 only")
+	logFetchCommand.Flags().StringVar(&logUntil, "until", "", "Fetch logs until this date, format: 2006-01-02, sf3500 only")
+	logFetchCommand.Flags().StringVar(&photoDir, "photo-dir", "", "Save log photos as image files in this directory instead of inline data, sf3500 only")
+	logCommand.AddCommand(logFetchCommand)
+	RootCmd.AddCommand(logCommand)
+}
+
+func fetchLog(cmd *cobra.Command, args []string) error {
+	if model != "sf3500" {
+		for _, name := range sf3500LogFlags {
+			if cmd.Flags().Changed(name) {
+				return usageErrorf("--%s is available for sf3500 only", name)
+			}
+		}
+	}
+	var filter sf3500.LogFilter
+	filter.NewOnly = logNewOnly
+	if logSince != "" {
+		if t, err := time.ParseInLocation("2006-01-02", logSince, time.Local); err == nil {
+			filter.BeginTime = t
+		} else {
+			return usageErrorf("invalid date format: %s", logSince)
+		}
+	}
+	if logUntil != "" {
+		if t, err := time.ParseInLocation("2006-01-02", logUntil, time.Local); err == nil {
+			filter.EndTime = t
+		} else {
+			return usageErrorf("invalid date format: %s", logUntil)
+		}
+	}
+	if logMarkRead && outputFile == "" {
+		return usageErrorf("--mark-read requires --output-file, logs are only marked as read after they are stored")
+	}
+	device, err := connect()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	if sf, ok := device.(*absen.Sf3500Device); ok {
+		return fetchSf3500Log(sf, filter)
+	}
+	source, ok := device.(absen.LogSource)
+	if !ok {
+		return unsupported(device, "logs")
+	}
+	punches, err := source.Punches()
+	// logs fetched before an error may be already removed from the machine, write them anyway
+	if writeErr := writeLogs(punches); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// Fetch SF3500 logs matching the filter, unread logs are marked as read after written to output file when requested
+func fetchSf3500Log(device *absen.Sf3500Device, filter sf3500.LogFilter) error {
+	if outputFormat == "ndjson" {
+		return reportUnpersisted(streamSf3500Log(device, filter))
+	}
+	if logMarkRead {
+		// rewrite output file with all persisted logs every time new logs arrived
+		punches := make([]absen.Punch, 0)
+		_, err := device.FetchPunchesAndMark(filter, func(list []absen.Punch) error {
+			if err := exportLogPhotos(device, list); err != nil {
+				return err
+			}
+			punches = append(punches, list...)
+			return writeLogs(punches)
+		})
+		return reportUnpersisted(err)
+	}
+	punches, err := device.FetchPunches(filter, false)
+	if err != nil {
+		return err
+	}
+	if err := exportLogPhotos(device, punches); err != nil {
+		return err
+	}
+	return writeLogs(punches)
+}
+
+// Print logs marked as read but not written to output file to standard error, so they are not lost
+func reportUnpersisted(err error) error {
+	var unpersisted *sf3500.UnpersistedLogsError
+	if errors.As(err, &unpersisted) {
+		if data, e := json.Marshal(unpersisted.Logs); e == nil {
+			fmt.Fprintln(os.Stderr, string(data))
+		}
+	}
+	return err
+}
+
+// Write logs in ndjson format as they arrive. With --mark-read, logs are appended to output file
+// and marked as read after synced to disk.
+func streamSf3500Log(device *absen.Sf3500Device, filter sf3500.LogFilter) error {
+	return streamOutput(logMarkRead, func(emit func(write func(writer io.Writer) error) error) error {
+		count := 0
+		handle := func(list []absen.Punch) error {
+			if err := exportLogPhotos(device, list); err != nil {
+				return err
+			}
+			err := emit(func(writer io.Writer) error {
+				return renderLogs(writer, list, count)
+			})
+			count += len(list)
+			return err
+		}
+		if logMarkRead {
+			_, err := device.FetchPunchesAndMark(filter, handle)
+			return err
+		}
+		return device.StreamPunches(filter, handle)
+	})
+}
+
+// Save photos of SF3500 logs to photo directory, photo data of the logs are replaced by file names
+func exportLogPhotos(device *absen.Sf3500Device, punches []absen.Punch) error {
+	if photoDir == "" {
+		return nil
+	}
+	logs := make([]models.LogData, 0, len(punches))
+	for _, punch := range punches {
+		logs = append(logs, punch.Raw.(models.LogData))
+	}
+	if err := sf3500.ExportLogPhotos(photoDir, device.ID(), logs); err != nil {
+		return err
+	}
+	for i := range punches {
+		punches[i].Raw = logs[i]
+	}
+	return nil
+}
+
+// Write punches or logs of the machine to output file or standard output in selected format
+func writeLogs(punches []absen.Punch) error {
+	return writeOutput(func(writer io.Writer) error {
+		return renderLogs(writer, punches, 0)
+	})
+}
+
+// Render punches numbered after the count of punches written before
+func renderLogs(writer io.Writer, punches []absen.Punch, count int) error {
+	var value interface{} = punches
+	if logRaw {
+		raw := make([]interface{}, 0, len(punches))
+		for _, punch := range punches {
+			raw = append(raw, punch.Raw)
+		}
+		value = raw
+	}
+	rows := make([][]string, 0, len(punches))
+	for i, punch := range punches {
+		rows = append(rows, []string{strconv.Itoa(count + i + 1), punch.DeviceID, punch.SubjectID, formatTime(punch.Timestamp),
+			string(punch.Credential), string(punch.Direction), string(punch.Result)})
+	}
+	return renderTo(writer, value, []string{"No", "Device ID", "User ID", "Time", "Credential", "Direction", "Result"}, rows)
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/masykur/absen"
+	"github.com/spf13/cobra"
+)
+
+// represents the machine command
+var machineCommand = &cobra.Command{
+	Use:   "machine",
+	Short: "Manage attandance machine",
+	Long:  "Obtain information and configuration of the machine"}
+
+var machineGetCommand = &cobra.Command{
+	Use:   "get",
+	Short: "Obtain attandance machine information",
+	Long:  "Obtain capabilities, product code, serial number and device info of the machine"}
+
+var machineConfigCommand = &cobra.Command{
+	Use:         "config",
+	Short:       "Manage machine configuration",
+	Long:        "Obtain and change operating parameters of the machine",
+	Annotations: forModels("sf3500")}
+
+var machineConfigGetCommand = &cobra.Command{
+	Use:   "get",
+	Short: "Obtain machine configuration in json format",
+	Args:  cobra.ExactArgs(0),
+	RunE:  getDeviceConfig}
+
+var machineConfigSetCommand = &cobra.Command{
+	Use:     "set",
+	Short:   "Change machine configuration",
+	Long:    "Change machine configuration from json file, parameters not specified in the file are kept unchanged",
+	Example: "absen --model sf3500 machine config set --host 192.168.0.1 --file config.json",
+	Args:    cobra.ExactArgs(0),
+	RunE:    setDeviceConfig}
+
+func init() {
+	machineConfigSetCommand.Flags().StringVarP(&inputFile, "file", "i", "", "Read configuration from json file")
+	machineConfigCommand.AddCommand(machineConfigGetCommand)
+	machineConfigCommand.AddCommand(machineConfigSetCommand)
+	machineCommand.AddCommand(machineConfigCommand)
+	machineCommand.AddCommand(machineGetCommand)
+	machineGetCommand.AddCommand(&cobra.Command{
+		Use:     "capabilities",
+		Aliases: []string{"c", "cap"},
+		Short:   "Obtain machine id and features supported by the machine",
+		Args:    cobra.ExactArgs(0),
+		RunE:    getCapabilities})
+	machineGetCommand.AddCommand(&cobra.Command{
+		Use:         "info",
+		Short:       "Obtain machine info",
+		Annotations: forModels("sf3500"),
+		Args:        cobra.ExactArgs(0),
+		RunE:        getDeviceInfo})
+	machineGetCommand.AddCommand(&cobra.Command{
+		Use:         "product-code",
+		Aliases:     []string{"p", "prod", "product"},
+		Short:       "Obtain machine product code",
+		Annotations: forModels("sf3000"),
+		Args:        cobra.ExactArgs(0),
+		RunE:        getProductCode})
+	machineGetCommand.AddCommand(&cobra.Command{
+		Use:         "serial-number",
+		Aliases:     []string{"s", "sn"},
+		Short:       "Obtain machine serial number",
+		Annotations: forModels("sf3000"),
+		Args:        cobra.ExactArgs(0),
+		RunE:        getSerialNumber})
+
+	RootCmd.AddCommand(machineCommand)
+}
+
+// Machine capabilities in json output
+type machineCapabilities struct {
+	Model        string   `json:"model"`
+	ID           string   `json:"id"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Obtain machine id and capabilities declared by the driver
+func getCapabilities(cmd *cobra.Command, args []string) error {
+	device, err := connect()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	value := machineCapabilities{Model: device.Model(), ID: device.ID(), Capabilities: device.Capabilities().Names()}
+	rows := [][]string{{value.Model, value.ID, strings.Join(value.Capabilities, ", ")}}
+	return render(value, []string{"Model", "ID", "Capabilities"}, rows)
+}
+
+// Obtain product code
+func getProductCode(cmd *cobra.Command, args []string) error {
+	device, err := connect()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	if productCode, err := device.(*absen.Sf3000Device).Driver().GetProductCode(); err == nil {
+		return render(map[string]string{"productCode": productCode}, []string{"Product Code"}, [][]string{{productCode}})
+	} else {
+		return err
+	}
+}
+
+// Obtain serial number
+func getSerialNumber(cmd *cobra.Command, args []string) error {
+	device, err := connect()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	if serialNumber, err := device.(*absen.Sf3000Device).Driver().GetSerialNumber(); err == nil {
+		return render(map[string]string{"serialNumber": serialNumber}, []string{"Serial Number"}, [][]string{{serialNumber}})
+	} else {
+		return err
+	}
+}
+
+// Obtain SF3500 device info
+func getDeviceInfo(cmd *cobra.Command, args []string) error {
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	deviceInfo, err := device.GetDeviceInfo()
+	if err != nil {
+		return err
+	}
+	rows := [][]string{
+		{"ID", deviceInfo.DeviceID},
+		{"Name", deviceInfo.Name},
+		{"Firmware", deviceInfo.Firmware},
+		{"Fingerprint Version", deviceInfo.FingerprintVersion},
+		{"Face Version", deviceInfo.FaceVersion},
+		{"Palmprint Version", deviceInfo.PalmprintVersion},
+		{"Maximum Buffer Length", strconv.Itoa(deviceInfo.MaximumBufferLength)},
+		{"User Limit", strconv.Itoa(deviceInfo.UserLimit)},
+		{"Fingerprint Limit", strconv.Itoa(deviceInfo.FingerprintLimit)},
+		{"Face Limit", strconv.Itoa(deviceInfo.FaceLimit)},
+		{"Password Limit", strconv.Itoa(deviceInfo.PasswordLimit)},
+		{"Card Limit", strconv.Itoa(deviceInfo.CardLimit)},
+		{"Log Limit", strconv.Itoa(deviceInfo.LogLimit)},
+		{"User Count", strconv.Itoa(deviceInfo.UserCount)},
+		{"Manager Count", strconv.Itoa(deviceInfo.ManagerCount)},
+		{"Fingerprint Count", strconv.Itoa(deviceInfo.FingerprintCount)},
+		{"Face Count", strconv.Itoa(deviceInfo.FaceCount)},
+		{"Password Count", strconv.Itoa(deviceInfo.PasswordCount)},
+		{"Card Count", strconv.Itoa(deviceInfo.CardCount)},
+		{"Log Count", strconv.Itoa(deviceInfo.LogCount)},
+		{"All Logs Count", strconv.Itoa(deviceInfo.AllLogCount)}}
+	return render(deviceInfo, []string{"Name", "Value"}, rows)
+}
+
+// Obtain machine configuration
+func getDeviceConfig(cmd *cobra.Command, args []string) error {
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	config, err := device.GetDeviceConfig()
+	if err != nil {
+		return err
+	}
+	rows, err := fieldRows(config)
+	if err != nil {
+		return err
+	}
+	return render(config, []string{"Name", "Value"}, rows)
+}
+
+// Change machine configuration
+func setDeviceConfig(cmd *cobra.Command, args []string) error {
+	if inputFile == "" {
+		return usageErrorf("--file is required")
+	}
+	jsonText, err := os.ReadFile(inputFile)
+	if err != nil {
+		return usageError{err}
+	}
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	// apply configuration file on top of current configuration
+	// so parameters not specified in the file are kept unchanged
+	config, err := device.GetDeviceConfig()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(jsonText, &config); err != nil {
+		return usageErrorf("invalid json format: %v", err)
+	}
+	ok, err := device.SetDeviceConfig(config)
+	return absen.DriverError(ok, err, "set configuration")
+}
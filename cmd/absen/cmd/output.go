@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Layout of date and time in arguments
+const timeLayout = "2006-01-02 15:04:05"
+
+var outputFormats = []string{"json", "ndjson", "yaml", "csv", "table", "template"}
+
+var (
+	outputFormat   string
+	outputFile     string
+	outputColumns  []string
+	outputTemplate string
+	outputTime     string
+
+	// parsed --template
+	tmpl *template.Template
+)
+
+func init() {
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "f", "json", "Available format: "+strings.Join(outputFormats, ", "))
+	RootCmd.PersistentFlags().StringVarP(&outputFile, "output-file", "o", "", "Write output to file")
+	RootCmd.PersistentFlags().StringSliceVar(&outputColumns, "columns", nil, "Write these columns only, column names are table headers in lower case with dashes, e.g. user-id,time")
+	RootCmd.PersistentFlags().StringVar(&outputTemplate, "template", "", "Go template executed for each item in template format, e.g. '{{.SubjectID}} {{time .Timestamp}}'")
+	RootCmd.PersistentFlags().StringVar(&outputTime, "time-format", time.RFC3339, "Layout of date and time in table, csv and template output, json and yaml use RFC3339")
+}
+
+// Check output flags, template format is selected when --template is given without output format
+func checkOutputFlags(cmd *cobra.Command) error {
+	if outputTemplate != "" && !cmd.Flags().Changed("output-format") {
+		outputFormat = "template"
+	}
+	if !contains(outputFormats, outputFormat) {
+		return usageErrorf("invalid output format %q, use %s", outputFormat, strings.Join(outputFormats, ", "))
+	}
+	if outputFormat == "template" {
+		if outputTemplate == "" {
+			return usageErrorf("--template is required by template format")
+		}
+		t, err := template.New("output").Funcs(template.FuncMap{
+			"time": formatTime,
+			"json": func(value interface{}) (string, error) {
+				data, err := json.Marshal(value)
+				return string(data), err
+			}}).Parse(outputTemplate)
+		if err != nil {
+			return usageErrorf("invalid template: %v", err)
+		}
+		tmpl = t
+	}
+	return nil
+}
+
+// Format date and time by --time-format
+func formatTime(t time.Time) string {
+	return t.Format(outputTime)
+}
+
+// Open output file or standard output, output file is truncated or appended to when appendFile is true
+func openOutput(appendFile bool) (*os.File, error) {
+	if outputFile == "" {
+		return os.Stdout, nil
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendFile {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	return os.OpenFile(outputFile, flag, 0644)
+}
+
+// Close output file, standard output is kept open
+func closeOutput(f *os.File) error {
+	if f == os.Stdout {
+		return nil
+	}
+	return f.Close()
+}
+
+// Sync output file to disk, standard output is not synced
+func syncOutput(f *os.File) error {
+	if f == os.Stdout {
+		return nil
+	}
+	return f.Sync()
+}
+
+// Write output to output file or standard output. Output file is synced to disk before returning,
+// so commands can rely on the result, e.g. to mark logs as read.
+func writeOutput(write func(writer io.Writer) error) error {
+	f, err := openOutput(false)
+	if err != nil {
+		return err
+	}
+	if f == os.Stdout {
+		return write(f)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write output in parts as they are produced, produce passes a function writing each part to emit.
+// Each part is synced to output file before the next part is produced, output file is appended to when appendFile is true.
+// Used in ndjson format, so items are written while the rest of them are read from machine.
+func streamOutput(appendFile bool, produce func(emit func(write func(writer io.Writer) error) error) error) error {
+	f, err := openOutput(appendFile)
+	if err != nil {
+		return err
+	}
+	err = produce(func(write func(writer io.Writer) error) error {
+		if err := write(f); err != nil {
+			return err
+		}
+		return syncOutput(f)
+	})
+	if closeErr := closeOutput(f); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Render value in json, ndjson, yaml and template format, or header and rows in table and csv format.
+// Items of list value are written one per line in ndjson format and the template is executed for each item.
+func render(value interface{}, header []string, rows [][]string) error {
+	return writeOutput(func(writer io.Writer) error {
+		return renderTo(writer, value, header, rows)
+	})
+}
+
+func renderTo(writer io.Writer, value interface{}, header []string, rows [][]string) error {
+	header, rows, err := selectColumns(header, rows)
+	if err != nil {
+		return err
+	}
+	if len(outputColumns) > 0 {
+		// structured output has the selected columns only
+		value = columnRecords(header, rows)
+	}
+	switch outputFormat {
+	case "json":
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(writer, string(data))
+		return err
+	case "ndjson":
+		return eachItem(value, func(item interface{}) error {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(writer, string(data))
+			return err
+		})
+	case "yaml":
+		data, err := toYAML(value)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	case "csv":
+		w := csv.NewWriter(writer)
+		w.Write(header)
+		w.WriteAll(rows)
+		return w.Error()
+	case "table":
+		table := tablewriter.NewWriter(writer)
+		table.SetHeader(header)
+		table.AppendBulk(rows)
+		table.Render()
+		return nil
+	case "template":
+		return eachItem(value, func(item interface{}) error {
+			if record, ok := item.(columnRecord); ok {
+				// selected columns are accessed by name, e.g. {{index . "user-id"}}
+				item = record.fields()
+			}
+			if err := tmpl.Execute(writer, item); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintln(writer)
+			return err
+		})
+	default:
+		return usageErrorf("invalid output format %q", outputFormat)
+	}
+}
+
+// Column name of table header, e.g. user-id of User ID
+func columnName(header string) string {
+	return strings.ReplaceAll(strings.ToLower(header), " ", "-")
+}
+
+// Keep columns selected by --columns in the selected order
+func selectColumns(header []string, rows [][]string) ([]string, [][]string, error) {
+	if len(outputColumns) == 0 {
+		return header, rows, nil
+	}
+	names := make([]string, 0, len(header))
+	for _, item := range header {
+		names = append(names, columnName(item))
+	}
+	indexes := make([]int, 0, len(outputColumns))
+	for _, column := range outputColumns {
+		index := -1
+		for i, name := range names {
+			if name == strings.ToLower(column) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, nil, usageErrorf("unknown column %q, use %s", column, strings.Join(names, ", "))
+		}
+		indexes = append(indexes, index)
+	}
+	selectedHeader := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		selectedHeader = append(selectedHeader, header[index])
+	}
+	selectedRows := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		selected := make([]string, 0, len(indexes))
+		for _, index := range indexes {
+			selected = append(selected, row[index])
+		}
+		selectedRows = append(selectedRows, selected)
+	}
+	return selectedHeader, selectedRows, nil
+}
+
+// Row of selected columns in structured output, columns keep their order
+type columnRecord struct {
+	names  []string
+	values []string
+}
+
+func (r columnRecord) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, name := range r.names {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		value, _ := json.Marshal(r.values[i])
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+func (r columnRecord) fields() map[string]string {
+	fields := make(map[string]string, len(r.names))
+	for i, name := range r.names {
+		fields[name] = r.values[i]
+	}
+	return fields
+}
+
+func columnRecords(header []string, rows [][]string) []columnRecord {
+	names := make([]string, 0, len(header))
+	for _, item := range header {
+		names = append(names, columnName(item))
+	}
+	records := make([]columnRecord, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, columnRecord{names, row})
+	}
+	return records
+}
+
+// Call write for each item of list value, or once for other value
+func eachItem(value interface{}, write func(item interface{}) error) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return write(value)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := write(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert value to yaml with the same keys and order as json
+func toYAML(value interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	// json is a subset of yaml, the parsed document is written in block style
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Rows of top level fields of value in json format sorted by name, for values without natural table layout
+func fieldRows(value interface{}) ([][]string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		text := string(fields[name])
+		var s string
+		if json.Unmarshal(fields[name], &s) == nil {
+			text = s
+		}
+		rows = append(rows, []string{name, text})
+	}
+	return rows, nil
+}
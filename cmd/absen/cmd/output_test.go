@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamOutput(t *testing.T) {
+	defer func(file string, format string) { outputFile, outputFormat = file, format }(outputFile, outputFormat)
+	outputFormat = "ndjson"
+	tests := []struct {
+		name       string
+		appendFile bool
+		want       string
+	}{
+		{"truncate", false, "{\"n\":1}\n{\"n\":2}\n"},
+		{"append", true, "{\"n\":0}\n{\"n\":1}\n{\"n\":2}\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outputFile = filepath.Join(t.TempDir(), "out.ndjson")
+			if err := os.WriteFile(outputFile, []byte("{\"n\":0}\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := streamOutput(test.appendFile, func(emit func(write func(writer io.Writer) error) error) error {
+				for n := 1; n <= 2; n++ {
+					item := map[string]int{"n": n}
+					if err := emit(func(writer io.Writer) error {
+						return renderTo(writer, []interface{}{item}, nil, nil)
+					}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("output = %q, want %q", data, test.want)
+			}
+		})
+	}
+}
+
+func TestStreamOutputStopsOnError(t *testing.T) {
+	defer func(file string, format string) { outputFile, outputFormat = file, format }(outputFile, outputFormat)
+	outputFormat = "ndjson"
+	outputFile = filepath.Join(t.TempDir(), "out.ndjson")
+	parts := 0
+	err := streamOutput(false, func(emit func(write func(writer io.Writer) error) error) error {
+		for n := 1; n <= 3; n++ {
+			parts++
+			if err := emit(func(writer io.Writer) error {
+				if n == 2 {
+					return fmt.Errorf("disk full")
+				}
+				return renderTo(writer, []int{n}, nil, nil)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil || parts != 2 {
+		t.Errorf("error = %v after %d parts, want error after 2 parts", err, parts)
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes
+const (
+	exitFailure = 1 // operation on machine failed
+	exitUsage   = 2 // invalid command, flags, arguments or input data
+)
+
+var (
+	model    string
+	host     string
+	port     int
+	nid      uint16
+	password uint16
+	timeout  time.Duration
+	timezone string
+
+	// set when flags and arguments are valid and the command starts running
+	started bool
+)
+
+// RootCmd represents the base command when called without any subcommands
+var RootCmd = &cobra.Command{
+	Use:   "absen",
+	Short: "Time attendance and access control machine command line interface",
+	Long: "Application to manage Keico SF3000, Keico SF3500 and RECO RAC2000 machines.\n" +
+		"Exit code is 1 when an operation on machine fails and 2 when command, flags or input data are invalid.",
+	Version:           "0.7.0",
+	SilenceErrors:     true,
+	SilenceUsage:      true,
+	PersistentPreRunE: checkFlags}
+
+// Invalid command, flags, arguments or input data
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func usageErrorf(format string, a ...interface{}) error {
+	return usageError{fmt.Errorf(format, a...)}
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	bindCardFacilityFlags()
+	fleetCommands(RootCmd)
+	cmd, err := RootCmd.ExecuteC()
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	var usageErr usageError
+	if !started || errors.As(err, &usageErr) {
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", cmd.CommandPath())
+		os.Exit(exitUsage)
+	}
+	os.Exit(exitFailure)
+}
+
+// Run as command line interface of a single model, used by sf3000, sf3500 and rac2000 binaries.
+// Model flag is preset and commands of other models are hidden.
+func ExecuteModel(name string) {
+	driver, ok := absen.Lookup(name)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Error:", absen.UnknownModelError(name))
+		os.Exit(exitUsage)
+	}
+	// preset model is given, so ABSEN_MODEL and model of device profile don't override it
+	RootCmd.PersistentFlags().Set("model", driver.Model)
+	RootCmd.Use = driver.Model
+	RootCmd.Short = driver.Description + " command line interface"
+	RootCmd.Long = "Application to manage " + driver.Description + ", alias of absen --model " + driver.Model + ".\n" +
+		"Exit code is 1 when an operation on machine fails and 2 when command, flags or input data are invalid."
+	modelFlag := RootCmd.PersistentFlags().Lookup("model")
+	modelFlag.DefValue = driver.Model
+	modelFlag.Hidden = true
+	hideCommands(RootCmd, driver.Model)
+	// users and logs are read and written in json format of the machine, as by the binaries the aliases replace
+	rawDefaults(RootCmd)
+	if driver.Model == "rac2000" {
+		// RAC2000 binary used -f for card facility code
+		cardFacilityShorthand = "f"
+	}
+	Execute()
+}
+
+// Turn on --raw of commands by default
+func rawDefaults(cmd *cobra.Command) {
+	for _, child := range cmd.Commands() {
+		rawDefaults(child)
+	}
+	if flag := cmd.Flags().Lookup("raw"); flag != nil {
+		flag.Value.Set("true")
+		flag.DefValue = "true"
+	}
+}
+
+func init() {
+	RootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	RootCmd.PersistentFlags().StringVarP(&model, "model", "m", "", "Machine model: "+strings.Join(absen.Models(), ", "))
+	RootCmd.PersistentFlags().StringVar(&host, "host", "", "Specify the host name or IP address of the remote machine to connect to")
+	RootCmd.PersistentFlags().IntVar(&port, "port", 0, "Specify the port number of the remote machine to connect to, default port of the model when 0")
+	RootCmd.PersistentFlags().Uint16Var(&nid, "nid", 1, "Specify the machine number")
+	RootCmd.PersistentFlags().Uint16Var(&password, "password", 0, "Specify the password to connect to remote machine")
+	RootCmd.PersistentFlags().DurationVar(&timeout, "timeout", absen.DefaultTimeout, "Timeout of connecting and each command sent to machine")
+	RootCmd.PersistentFlags().StringVar(&timezone, "timezone", "", "Time zone of the machine clock such as Asia/Jakarta, time zone of the client PC when empty")
+}
+
+// Apply configuration, check model is registered, the command is available for the model and output format is valid
+func checkFlags(cmd *cobra.Command, args []string) error {
+	if err := initConfig(cmd); err != nil {
+		return err
+	}
+	if model != "" {
+		driver, ok := absen.Lookup(model)
+		if !ok {
+			return usageError{absen.UnknownModelError(model)}
+		}
+		model = driver.Model
+	}
+	if models := commandModels(cmd); len(models) > 0 {
+		// devices of other models are skipped by --devices
+		if model == "" && len(fleetDevices) == 0 {
+			return usageErrorf("--model is required, %s is available for %s", cmd.CommandPath(), strings.Join(models, ", "))
+		}
+		if model != "" && !contains(models, model) {
+			return usageErrorf("%s is not available for %s, it is available for %s", cmd.CommandPath(), model, strings.Join(models, ", "))
+		}
+	}
+	if err := checkOutputFlags(cmd); err != nil {
+		return err
+	}
+	started = true
+	return nil
+}
+
+// Models the command is available for, from "models" annotation of the command or its parents. Empty for all models.
+func commandModels(cmd *cobra.Command) []string {
+	for c := cmd; c != nil; c = c.Parent() {
+		if value, ok := c.Annotations["models"]; ok {
+			return strings.Split(value, ",")
+		}
+	}
+	return nil
+}
+
+// Hide commands not available for the model
+func hideCommands(cmd *cobra.Command, model string) {
+	for _, child := range cmd.Commands() {
+		if models := commandModels(child); len(models) > 0 && !contains(models, model) {
+			child.Hidden = true
+			continue
+		}
+		hideCommands(child, model)
+	}
+}
+
+// Annotation of command available for the models only
+func forModels(models ...string) map[string]string {
+	return map[string]string{"models": strings.Join(models, ",")}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
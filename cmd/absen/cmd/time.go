@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/spf13/cobra"
+)
+
+// represents the time command
+var timeCommand = &cobra.Command{
+	Use:   "time",
+	Short: "Manage time",
+	Long:  "Obtain and set current time of the machine"}
+
+var timeSync bool
+
+// Machine date and time in json output
+type machineTime struct {
+	Time time.Time `json:"time"`
+}
+
+// Clock offset before and after synchronization in json output, positive when machine clock is ahead
+type clockOffset struct {
+	Before string `json:"offsetBefore"`
+	After  string `json:"offsetAfter"`
+}
+
+func init() {
+	timeCommand.AddCommand(&cobra.Command{
+		Use:   "get",
+		Short: "Obtain machine date and time",
+		Args:  cobra.ExactArgs(0),
+		RunE:  getTime})
+
+	timeSetCommand := &cobra.Command{
+		Use:     "set [value]",
+		Short:   "Set machine date and time",
+		Example: "To set machine date and time to specific value:\n\tabsen --model sf3000 time set \"2006-01-02 15:04:05\"\nTo set machine date and time follow the client PC:\n\tabsen --model sf3000 time set\nTo set machine date and time follow the client PC and report clock offset:\n\tabsen --model sf3500 time set --sync",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return errors.New("too many arguments")
+			}
+			if len(args) == 0 {
+				return nil
+			}
+			if timeSync {
+				return errors.New("value is not allowed when --sync is specified")
+			}
+			if _, err := time.ParseInLocation(timeLayout, args[0], time.Local); err == nil {
+				return nil
+			}
+			return fmt.Errorf("invalid date time format: %s", args[0])
+		},
+		RunE: setTime}
+	timeSetCommand.Flags().BoolVar(&timeSync, "sync", false, "Set machine date and time to client PC and report clock offset before and after")
+	timeCommand.AddCommand(timeSetCommand)
+
+	RootCmd.AddCommand(timeCommand)
+}
+
+// Connect to machine with clock
+func connectClock() (absen.Clock, error) {
+	device, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	if clock, ok := device.(absen.Clock); ok {
+		return clock, nil
+	}
+	device.Close()
+	return nil, unsupported(device, "clock")
+}
+
+func getTime(cmd *cobra.Command, args []string) error {
+	clock, err := connectClock()
+	if err != nil {
+		return err
+	}
+	defer clock.Close()
+	if dateTime, err := clock.Time(); err == nil {
+		return render(machineTime{dateTime}, []string{"Time"}, [][]string{{formatTime(dateTime)}})
+	} else {
+		return err
+	}
+}
+
+func setTime(cmd *cobra.Command, args []string) error {
+	clock, err := connectClock()
+	if err != nil {
+		return err
+	}
+	defer clock.Close()
+	if timeSync {
+		return syncTime(clock)
+	}
+	t := time.Now()
+	if len(args) > 0 {
+		t, _ = time.ParseInLocation(timeLayout, args[0], time.Local)
+	}
+	return clock.SetTime(t)
+}
+
+// Set machine date and time to client PC and report clock offset before and after
+func syncTime(clock absen.Clock) error {
+	before, err := absen.TimeOffset(clock)
+	if err != nil {
+		return err
+	}
+	if err := clock.SetTime(time.Now()); err != nil {
+		return err
+	}
+	after, err := absen.TimeOffset(clock)
+	if err != nil {
+		return err
+	}
+	value := clockOffset{before.String(), after.String()}
+	return render(value, []string{"Offset Before", "Offset After"}, [][]string{{value.Before, value.After}})
+}
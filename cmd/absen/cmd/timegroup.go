@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+	"github.com/spf13/cobra"
+)
+
+// represents the timegroup command
+var timeGroupCommand = &cobra.Command{
+	Use:         "timegroup",
+	Short:       "Manage access schedule",
+	Long:        "View and change time periods and time groups of the machine",
+	Annotations: forModels("sf3500")}
+
+var timeGroupListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "Retrieve time periods and time groups from the machine",
+	Args:  cobra.ExactArgs(0),
+	RunE:  getTimeGroups}
+
+var timeGroupSetCommand = &cobra.Command{
+	Use:   "set",
+	Short: "Write time periods and time groups to the machine",
+	Example: `absen --model sf3500 timegroup set --host 192.168.0.1 --file schedule.json
+where schedule.json is
+{"timePeriods":[{"id":1,"time":"0800-1200"},{"id":2,"time":"1300-1700"}],"timeGroups":[{"id":1,"name":"Office hours","periods":[1,2]}]}`,
+	Args: cobra.ExactArgs(0),
+	RunE: setTimeGroups}
+
+func init() {
+	timeGroupSetCommand.Flags().StringVarP(&inputFile, "file", "i", "", "Read time periods and time groups from json file")
+	timeGroupCommand.AddCommand(timeGroupListCommand)
+	timeGroupCommand.AddCommand(timeGroupSetCommand)
+	RootCmd.AddCommand(timeGroupCommand)
+}
+
+// Retrieve time periods and time groups
+func getTimeGroups(cmd *cobra.Command, args []string) error {
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	timeTable, err := device.GetTimeTable()
+	if err != nil {
+		return err
+	}
+	periods := make(map[int]string, len(timeTable.Periods))
+	for _, period := range timeTable.Periods {
+		periods[period.ID] = period.Time
+	}
+	rows := make([][]string, 0, len(timeTable.Groups))
+	for _, group := range timeTable.Groups {
+		times := make([]string, 0, len(group.Periods))
+		for _, id := range group.Periods {
+			times = append(times, periods[id])
+		}
+		rows = append(rows, []string{strconv.Itoa(group.ID), group.Name, strings.Join(times, ", ")})
+	}
+	return render(timeTable, []string{"Group ID", "Name", "Time Periods"}, rows)
+}
+
+// Write time periods and time groups
+func setTimeGroups(cmd *cobra.Command, args []string) error {
+	if inputFile == "" {
+		return usageErrorf("--file is required")
+	}
+	jsonText, err := os.ReadFile(inputFile)
+	if err != nil {
+		return usageError{err}
+	}
+	var timeTable models.TimeTable
+	if err := json.Unmarshal(jsonText, &timeTable); err != nil {
+		return usageErrorf("invalid json format: %v", err)
+	}
+	// validate before connecting so invalid schedule never reaches the machine
+	if err := sf3500.ValidateTimeTable(timeTable); err != nil {
+		return usageError{err}
+	}
+	_, device, err := connectSf3500()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	ok, err := device.SetTimeTable(timeTable)
+	return absen.DriverError(ok, err, "set time groups")
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/rac2000"
+	"github.com/masykur/absen/pkg/sf3000"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+	"github.com/spf13/cobra"
+)
+
+// represents the user command
+var userCommand = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user",
+	Long:  "View, enroll, and remove user from machine. Users of RAC2000 are cards, user id is facility code followed by 5 digits card number."}
+
+var userGetCommand = &cobra.Command{
+	Use:     "get [id,..]...",
+	Short:   "Obtain user information including templates",
+	Example: "absen --model sf3500 user get 12345678,12345688",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    getUser}
+
+var userSetCommand = &cobra.Command{
+	Use:   "set",
+	Short: "Enroll user to machine or update existing user",
+	Long: "Enroll users in json format, single user or array of users. Fingerprint templates and face are base64 encoded in the format of the machine.\n" +
+		"With --raw, users are read in json format of the machine, e.g. output of user get --raw.",
+	Example: `absen --model sf3500 user set --host 192.168.0.1 -d '{"id":"12345678","name":"John","privilege":0,"card":"1234567890","password":"","fingerprints":[],"face":""}'` + "\n" +
+		`absen --model sf3000 user set --host 192.168.0.1 --nid 123 --raw -d '{"Id":12345678,"CardFacilityCode":186,"CardId":45123,"Fingerprint1":"","Fingerprint2":""}'`,
+	Args: cobra.ExactArgs(0),
+	RunE: setUser}
+
+var userDelCommand = &cobra.Command{
+	Use:     "del [id] [id]...",
+	Short:   "Remove user from machine",
+	Example: "absen --model sf3500 user del 12345678 12345688",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    delUser}
+
+var userCopyCommand = &cobra.Command{
+	Use:         "copy",
+	Short:       "Copy users from one machine to other machines",
+	Long:        "Copy users including face, fingerprints, card and password from one machine to other machines",
+	Example:     "absen --model sf3500 user copy --from 192.168.0.1 --to 192.168.0.2,192.168.0.3:5005 --id 12345678,12345688",
+	Annotations: forModels("sf3500"),
+	Args:        cobra.ExactArgs(0),
+	RunE:        copyUser}
+
+var userCountCommand = &cobra.Command{
+	Use:   "count",
+	Short: "Obtain number of users registered in the machine",
+	Args:  cobra.ExactArgs(0),
+	RunE:  getUserCount}
+
+var userListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "Retrieve list of users registered in the machine",
+	Args:  cobra.ExactArgs(0),
+	RunE:  getUsers}
+
+var (
+	userRaw   bool
+	data      string
+	inputFile string
+	copyFrom  string
+	copyTo    []string
+	copyIds   []string
+)
+
+// Number of users in json output
+type userCount struct {
+	Count int `json:"count"`
+}
+
+func init() {
+	userGetCommand.Flags().BoolVar(&userRaw, "raw", false, "Write users in json format of the machine")
+	userGetCommand.Flags().StringVar(&photoDir, "photo-dir", "", "Save user photos and faces as image files in this directory instead of inline data, sf3500 only")
+	userListCommand.Flags().BoolVar(&userRaw, "raw", false, "Write users in json format of the machine")
+	userSetCommand.Flags().BoolVar(&userRaw, "raw", false, "Read users in json format of the machine")
+	userSetCommand.Flags().StringVarP(&inputFile, "input-file", "i", "", "Read input from json file")
+	userSetCommand.Flags().StringVarP(&data, "data", "d", "", "Input data in json format")
+	userCommand.AddCommand(userGetCommand)
+	userCommand.AddCommand(userCountCommand)
+	userCommand.AddCommand(userListCommand)
+	userCommand.AddCommand(userSetCommand)
+	userCommand.AddCommand(userDelCommand)
+	userCopyCommand.Flags().StringVar(&copyFrom, "from", "", "Source machine, host name or IP address with optional port, --host when empty")
+	userCopyCommand.Flags().StringSliceVar(&copyTo, "to", nil, "Target machines, host name or IP address with optional port, separated by comma")
+	userCopyCommand.Flags().StringSliceVar(&copyIds, "id", nil, "User ids to copy, separated by comma")
+	userCommand.AddCommand(userCopyCommand)
+	RootCmd.AddCommand(userCommand)
+}
+
+// Connect to machine keeping users
+func connectUserStore() (absen.UserStore, error) {
+	device, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	if store, ok := device.(absen.UserStore); ok {
+		return store, nil
+	}
+	device.Close()
+	return nil, unsupported(device, "users")
+}
+
+// Obtain number of users registered in the machine
+func getUserCount(cmd *cobra.Command, args []string) error {
+	store, err := connectUserStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	var count int
+	switch device := store.(type) {
+	case *absen.Sf3500Device:
+		deviceInfo, err := device.Driver().GetDeviceInfo()
+		if err != nil {
+			return err
+		}
+		count = deviceInfo.UserCount
+	case *absen.Sf3000Device:
+		if count, err = device.Driver().GetUserCount(); err != nil {
+			return err
+		}
+	default:
+		users, err := store.Users()
+		if err != nil {
+			return err
+		}
+		count = len(users)
+	}
+	return render(userCount{count}, []string{"Count"}, [][]string{{strconv.Itoa(count)}})
+}
+
+// Retrieve list of users registered in the machine
+func getUsers(cmd *cobra.Command, args []string) error {
+	store, err := connectUserStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if sf, ok := store.(*absen.Sf3500Device); ok && outputFormat == "ndjson" {
+		// users are written as packages of them are read
+		return streamOutput(false, func(emit func(write func(writer io.Writer) error) error) error {
+			count := 0
+			return sf.StreamUsers(func(users []absen.User) error {
+				err := emit(func(writer io.Writer) error {
+					return renderUsersTo(writer, users, count)
+				})
+				count += len(users)
+				return err
+			})
+		})
+	}
+	users, err := store.Users()
+	if err != nil {
+		return err
+	}
+	return renderUsers(users)
+}
+
+// Obtain users by id
+func getUser(cmd *cobra.Command, args []string) error {
+	if photoDir != "" && model != "sf3500" {
+		return usageErrorf("--photo-dir is available for sf3500 only")
+	}
+	ids := make([]string, 0, len(args))
+	for _, arg := range args {
+		ids = append(ids, strings.Split(arg, ",")...)
+	}
+	store, err := connectUserStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	var users []absen.User
+	if reader, ok := store.(absen.UserReader); ok {
+		if users, err = reader.UsersByID(ids...); err != nil {
+			return err
+		}
+	} else {
+		// machine can't read users by id, find them in the user list
+		list, err := store.Users()
+		if err != nil {
+			return err
+		}
+		for _, user := range list {
+			if contains(ids, user.ID) {
+				users = append(users, user)
+			}
+		}
+	}
+	if sf, ok := store.(*absen.Sf3500Device); ok && photoDir != "" {
+		list := make([]models.User, 0, len(users))
+		for _, user := range users {
+			list = append(list, user.Raw.(models.User))
+		}
+		if err := sf3500.ExportUserPhotos(photoDir, sf.ID(), time.Now(), list); err != nil {
+			return err
+		}
+		for i := range users {
+			users[i].Raw = list[i]
+			users[i].Face = list[i].Face
+		}
+	}
+	return renderUsers(users)
+}
+
+func renderUsers(users []absen.User) error {
+	return writeOutput(func(writer io.Writer) error {
+		return renderUsersTo(writer, users, 0)
+	})
+}
+
+// Render users numbered after the count of users written before
+func renderUsersTo(writer io.Writer, users []absen.User, count int) error {
+	var value interface{} = users
+	if userRaw {
+		raw := make([]interface{}, 0, len(users))
+		for _, user := range users {
+			raw = append(raw, user.Raw)
+		}
+		value = raw
+	}
+	rows := make([][]string, 0, len(users))
+	for i, user := range users {
+		face := ""
+		if user.Face != "" {
+			face = "yes"
+		}
+		rows = append(rows, []string{strconv.Itoa(count + i + 1), user.ID, user.Name, strconv.Itoa(user.Privilege), user.Card,
+			strconv.Itoa(len(user.Fingerprints)), face})
+	}
+	return renderTo(writer, value, []string{"No", "User ID", "Name", "Privilege", "Card", "Fingerprints", "Face"}, rows)
+}
+
+// Parse array of items into list or single item into item, returns true when input is an array
+func parseList(jsonText []byte, list interface{}, item interface{}) (bool, error) {
+	text := bytes.TrimSpace(jsonText)
+	if len(text) > 0 && text[0] == '[' {
+		return true, json.Unmarshal(text, list)
+	}
+	return false, json.Unmarshal(text, item)
+}
+
+// Enroll users to machine or update existing users
+func setUser(cmd *cobra.Command, args []string) error {
+	var jsonText []byte
+	if data != "" {
+		jsonText = []byte(data)
+	} else if inputFile != "" {
+		var err error
+		if jsonText, err = os.ReadFile(inputFile); err != nil {
+			return usageError{err}
+		}
+	} else {
+		return usageErrorf("no input data available, use --data or --input-file")
+	}
+	if userRaw {
+		return setRawUsers(jsonText)
+	}
+	users := make([]absen.User, 0)
+	var user absen.User
+	if isList, err := parseList(jsonText, &users, &user); err != nil {
+		return usageErrorf("invalid json format: %v", err)
+	} else if !isList {
+		users = append(users, user)
+	}
+	for i, user := range users {
+		if strings.TrimSpace(user.ID) == "" {
+			return usageErrorf("id of user %d is empty, use --raw for users in json format of the machine", i+1)
+		}
+	}
+	store, err := connectUserStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return store.SetUsers(users...)
+}
+
+// Enroll users in json format of the machine
+func setRawUsers(jsonText []byte) error {
+	var set func(device absen.Device) error
+	switch model {
+	case "sf3500":
+		users := make([]models.User, 0)
+		var user models.User
+		if isList, err := parseList(jsonText, &users, &user); err != nil {
+			return usageErrorf("invalid json format: %v", err)
+		} else if !isList {
+			users = append(users, user)
+		}
+		set = func(device absen.Device) error {
+			ok, err := device.(*absen.Sf3500Device).Driver().SetUserInfo(users...)
+			return absen.DriverError(ok, err, "enroll users")
+		}
+	case "sf3000":
+		users := make([]sf3000.User, 0)
+		var user sf3000.User
+		if isList, err := parseList(jsonText, &users, &user); err != nil {
+			return usageErrorf("invalid json format: %v", err)
+		} else if !isList {
+			users = append(users, user)
+		}
+		set = func(device absen.Device) error {
+			for _, user := range users {
+				ok, err := device.(*absen.Sf3000Device).Driver().SetEnrollData(user)
+				if err := absen.DriverError(ok, err, "enroll user "+strconv.Itoa(user.Id)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case "rac2000":
+		cards := make([]rac2000.Card, 0)
+		var card rac2000.Card
+		if isList, err := parseList(jsonText, &cards, &card); err != nil {
+			return usageErrorf("invalid json format: %v", err)
+		} else if !isList {
+			cards = append(cards, card)
+		}
+		set = func(device absen.Device) error {
+			for _, card := range cards {
+				ok, err := device.(*absen.Rac2000Device).Driver().AddCard(card)
+				if err := absen.DriverError(ok, err, "add card"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		return usageErrorf("--raw is not available for %s", model)
+	}
+	device, err := connect()
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	return set(device)
+}
+
+// Remove users from machine
+func delUser(cmd *cobra.Command, args []string) error {
+	store, err := connectUserStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if !absen.Supports(store, absen.CapDeleteUsers) {
+		return unsupported(store, "delete users")
+	}
+	return store.DeleteUsers(args...)
+}
+
+// Copy users from source machine to target machines
+func copyUser(cmd *cobra.Command, args []string) error {
+	if len(copyTo) == 0 || len(copyIds) == 0 {
+		return usageErrorf("--to and --id are required")
+	}
+	source := copyFrom
+	if source == "" {
+		source = host
+	}
+	// targets without port use --port when specified
+	driver, _ := absen.Lookup(model)
+	targetPort := driver.DefaultPort
+	if port != 0 {
+		targetPort = port
+	}
+	targets := make([]string, 0, len(copyTo))
+	for _, target := range copyTo {
+		targets = append(targets, absen.AddressWithPort(target, targetPort))
+	}
+	device, err := connectTo(source)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+	results, err := device.(*absen.Sf3500Device).Driver().CopyUsersTo(targets, timeout, copyIds...)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{result.Address, strconv.FormatBool(result.Success), result.Error})
+	}
+	if err := render(results, []string{"Address", "Success", "Error"}, rows); err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("copy to %d of %d machines failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/masykur/absen/cmd/absen/cmd"
+)
+
+func main() {
+	cmd.Execute()
+}
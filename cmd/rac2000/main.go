@@ -1,9 +1,10 @@
+// Alias of absen --model rac2000
 package main
 
 import (
-	"github.com/masykur/absen/cmd/rac2000/cmd"
+	"github.com/masykur/absen/cmd/absen/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	cmd.ExecuteModel("rac2000")
 }
@@ -1,9 +1,10 @@
+// Alias of absen --model sf3000
 package main
 
 import (
-	"github.com/masykur/absen/cmd/sf3000/cmd"
+	"github.com/masykur/absen/cmd/absen/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	cmd.ExecuteModel("sf3000")
 }
@@ -1,9 +1,10 @@
+// Alias of absen --model sf3500
 package main
 
 import (
-	"github.com/masykur/absen/cmd/sf3500/cmd"
+	"github.com/masykur/absen/cmd/absen/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	cmd.ExecuteModel("sf3500")
 }
@@ -0,0 +1,105 @@
+// Package config reads configuration file shared by the command line interfaces and the SF3500 server.
+//
+// Flags are resolved in this order, the first one given wins:
+// command line flag, environment variable, configuration file and default value of the flag.
+// Settings of a device profile in configuration file are applied in the same place as the configuration file,
+// so environment variables win over them too.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Default configuration file, absen/config.yaml in user configuration directory such as ~/.config
+func DefaultFile() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "absen", "config.yaml")
+	}
+	return ""
+}
+
+// Read configuration file in YAML, TOML or JSON format detected from file extension.
+// When file is empty the default file is read, missing default file results empty configuration.
+func Read(file string) (*viper.Viper, error) {
+	v := viper.New()
+	if file == "" {
+		file = DefaultFile()
+		if file == "" {
+			return v, nil
+		}
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			return v, nil
+		}
+	}
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read configuration file %s: %v", file, err)
+	}
+	return v, nil
+}
+
+// Section of configuration as settings, nil when the section doesn't exist.
+// Keys are in lower case.
+func Section(v *viper.Viper, key string) map[string]interface{} {
+	if value, ok := v.Get(key).(map[string]interface{}); ok {
+		return value
+	}
+	return nil
+}
+
+// Environment variable name of the flag, prefix and flag name in upper case with dashes replaced by underscores
+func EnvName(prefix string, flag string) string {
+	return strings.ToUpper(prefix + "_" + SettingKey(flag))
+}
+
+// Settings key of the flag, flag name with dashes replaced by underscores
+func SettingKey(flag string) string {
+	return strings.ReplaceAll(flag, "-", "_")
+}
+
+// Set flags not given in command line from environment variables, or from settings when environment variable is empty.
+// Flags set by Apply are marked as changed, so calling Apply again doesn't override them:
+// Apply with nil settings followed by Apply with settings of a profile keeps environment variables over the profile.
+func Apply(flags *pflag.FlagSet, envPrefix string, settings map[string]interface{}) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed {
+			return
+		}
+		name := EnvName(envPrefix, flag.Name)
+		if value := os.Getenv(name); value != "" {
+			if e := flags.Set(flag.Name, value); e != nil {
+				err = fmt.Errorf("invalid value %q of %s: %v", value, name, e)
+			}
+			return
+		}
+		key := SettingKey(flag.Name)
+		if value, ok := settings[key]; ok && value != nil {
+			text := settingText(value)
+			if e := flags.Set(flag.Name, text); e != nil {
+				err = fmt.Errorf("invalid value %q of %s: %v", text, key, e)
+			}
+		}
+	})
+	return err
+}
+
+// Setting value as flag value, list is separated by comma
+func settingText(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
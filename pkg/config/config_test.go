@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyPrecedence(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	host := flags.String("host", "localhost", "")
+	port := flags.Int("port", 5005, "")
+	nid := flags.Int("nid", 1, "")
+	timeout := flags.Duration("timeout", 0, "")
+	tags := flags.StringSlice("tags", []string{"default"}, "")
+	if err := flags.Parse([]string{"--host", "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_HOST", "10.0.0.2")
+	t.Setenv("TEST_PORT", "5006")
+	t.Setenv("TEST_NID", "")
+	settings := map[string]interface{}{"host": "10.0.0.3", "port": 5007, "nid": 2, "tags": []interface{}{"lobby", "floor-1"}}
+	if err := Apply(flags, "test", settings); err != nil {
+		t.Fatal(err)
+	}
+	// command line wins over environment, environment over settings, settings over default
+	if *host != "10.0.0.1" {
+		t.Errorf("host = %s, want command line value", *host)
+	}
+	if *port != 5006 {
+		t.Errorf("port = %d, want environment value", *port)
+	}
+	if *nid != 2 {
+		t.Errorf("nid = %d, want settings value when environment variable is empty", *nid)
+	}
+	if *timeout != 0 {
+		t.Errorf("timeout = %v, want default value", *timeout)
+	}
+	if !reflect.DeepEqual(*tags, []string{"lobby", "floor-1"}) {
+		t.Errorf("tags = %v, want list of settings replacing default", *tags)
+	}
+
+	// flags set by the first call are not overridden
+	if err := Apply(flags, "test", map[string]interface{}{"nid": 3, "timeout": "5s"}); err != nil {
+		t.Fatal(err)
+	}
+	if *nid != 2 || timeout.String() != "5s" {
+		t.Errorf("nid, timeout = %d, %v after second call, want 2, 5s", *nid, *timeout)
+	}
+}
+
+func TestApplyEnvironmentOverProfile(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	device := flags.String("device", "", "")
+	host := flags.String("host", "localhost", "")
+	port := flags.Int("port", 5005, "")
+	t.Setenv("TEST_DEVICE", "lobby")
+	t.Setenv("TEST_HOST", "10.0.0.2")
+	// profile is selected by the flag set from environment, then applied
+	if err := Apply(flags, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	profiles := map[string]map[string]interface{}{"lobby": {"host": "10.0.0.1", "port": 5006}}
+	if err := Apply(flags, "test", profiles[*device]); err != nil {
+		t.Fatal(err)
+	}
+	if *host != "10.0.0.2" {
+		t.Errorf("host = %s, want environment value over profile", *host)
+	}
+	if *port != 5006 {
+		t.Errorf("port = %d, want profile value", *port)
+	}
+}
+
+func TestApplyInvalidValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		settings map[string]interface{}
+	}{
+		{"environment", "abc", nil},
+		{"settings", "", map[string]interface{}{"port": "abc"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.Int("port", 5005, "")
+			t.Setenv("TEST_PORT", test.env)
+			if err := Apply(flags, "test", test.settings); err == nil {
+				t.Fatal("expected error of invalid port")
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if name := EnvName("sf3500", "replicate-to"); name != "SF3500_REPLICATE_TO" {
+		t.Errorf("EnvName = %s, want SF3500_REPLICATE_TO", name)
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	data := "devices:\n  Lobby:\n    host: 10.0.0.1\n    tags: [office]\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := Section(v, "devices")
+	// keys are in lower case
+	if _, ok := devices["lobby"].(map[string]interface{}); !ok {
+		t.Errorf("devices = %v, want lobby device", devices)
+	}
+	if Section(v, "groups") != nil {
+		t.Error("missing section is not nil")
+	}
+	if _, err := Read(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected error of missing configuration file given explicitly")
+	}
+}
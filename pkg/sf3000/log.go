@@ -129,9 +129,11 @@ func (dev *Sf3000) FetchAllLogs() (int, []Log, error) {
 			raw := make([]byte, 0, size)
 			for len(raw) < size {
 				buffer := make([]byte, 1460)
-				if cnt, err := dev.conn.Read(buffer); err == nil {
-					raw = append(raw, buffer[:cnt]...)
+				cnt, err := dev.conn.Read(buffer)
+				if err != nil {
+					return 0, []Log{}, fmt.Errorf("read log data failed: %v", err)
 				}
+				raw = append(raw, buffer[:cnt]...)
 			}
 			logs := make([]Log, 0, count)
 			for i := 0; i < size; i += 4 + 85*12 + 2 {
@@ -0,0 +1,12 @@
+package cmds
+
+import "github.com/masykur/absen/pkg/sf3500/models"
+
+type GetDeviceSetting struct {
+	Command string `json:"cmd"`
+}
+
+type SetDeviceSetting struct {
+	Command string              `json:"cmd"`
+	Data    models.DeviceConfig `json:"data"`
+}
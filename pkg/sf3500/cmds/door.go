@@ -0,0 +1,9 @@
+package cmds
+
+type SetDoorStatus struct {
+	Command string            `json:"cmd"`
+	Data    SetDoorStatusData `json:"data"`
+}
+type SetDoorStatusData struct {
+	Status string `json:"status"`
+}
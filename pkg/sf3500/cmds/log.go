@@ -11,3 +11,7 @@ type GetLogData struct {
 	EndTime   string `json:"endTime"`
 	ClearMark int    `json:"clearMark"`
 }
+
+type ClearLog struct {
+	Command string `json:"cmd"`
+}
@@ -0,0 +1,13 @@
+package cmds
+
+type GetDeviceTime struct {
+	Command string `json:"cmd"`
+}
+
+type SetDeviceTime struct {
+	Command string            `json:"cmd"`
+	Data    SetDeviceTimeData `json:"data"`
+}
+type SetDeviceTimeData struct {
+	Time string `json:"time"`
+}
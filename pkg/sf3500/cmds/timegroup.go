@@ -0,0 +1,23 @@
+package cmds
+
+import "github.com/masykur/absen/pkg/sf3500/models"
+
+type GetTimeTable struct {
+	Command string `json:"cmd"`
+}
+
+type SetTimePeriod struct {
+	Command string            `json:"cmd"`
+	Data    SetTimePeriodData `json:"data"`
+}
+type SetTimePeriodData struct {
+	Periods []models.TimePeriod `json:"timePeriods"`
+}
+
+type SetTimeGroup struct {
+	Command string           `json:"cmd"`
+	Data    SetTimeGroupData `json:"data"`
+}
+type SetTimeGroupData struct {
+	Groups []models.TimeGroup `json:"timeGroups"`
+}
@@ -1,5 +1,7 @@
 package cmds
 
+import "github.com/masykur/absen/pkg/sf3500/models"
+
 type GetUserList struct {
 	Command string          `json:"cmd"`
 	Data    GetUserListData `json:"data"`
@@ -16,3 +18,19 @@ type GetUserInfoData struct {
 	PackageID int      `json:"packageId"`
 	UsersId   []string `json:"usersId"`
 }
+
+type SetUserInfo struct {
+	Command string          `json:"cmd"`
+	Data    SetUserInfoData `json:"data"`
+}
+type SetUserInfoData struct {
+	Users []models.User `json:"users"`
+}
+
+type DeleteUser struct {
+	Command string         `json:"cmd"`
+	Data    DeleteUserData `json:"data"`
+}
+type DeleteUserData struct {
+	UsersId []string `json:"usersId"`
+}
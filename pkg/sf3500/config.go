@@ -0,0 +1,77 @@
+package sf3500
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Obtain operating parameters of the machine
+func (dev *Sf3500) GetDeviceConfig() (models.DeviceConfig, error) {
+	command := cmds.GetDeviceSetting{Command: "GetDeviceSetting"}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var config models.DeviceConfigResponse
+			if err := json.Unmarshal(response, &config); err == nil {
+				if config.ResultCode == 0 {
+					return config.ResultData, nil
+				} else {
+					return models.DeviceConfig{}, fmt.Errorf("error code %d", config.ResultCode)
+				}
+			} else {
+				return models.DeviceConfig{}, err
+			}
+		} else {
+			return models.DeviceConfig{}, err
+		}
+	} else {
+		return models.DeviceConfig{}, err
+	}
+}
+
+// Change operating parameters of the machine
+func (dev *Sf3500) SetDeviceConfig(config models.DeviceConfig) (bool, error) {
+	if err := validateDeviceConfig(config); err != nil {
+		return false, err
+	}
+	command := cmds.SetDeviceSetting{Command: "SetDeviceSetting", Data: config}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var result models.Response
+			if err := json.Unmarshal(response, &result); err == nil {
+				if result.ResultCode == 0 {
+					return true, nil
+				} else {
+					return false, fmt.Errorf("error code %d", result.ResultCode)
+				}
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
+
+func validateDeviceConfig(config models.DeviceConfig) error {
+	if config.Volume < 0 || config.Volume > 10 {
+		return fmt.Errorf("invalid volume %d, valid value is 0-10", config.Volume)
+	}
+	if config.DisplayTimeout < 0 {
+		return fmt.Errorf("invalid display timeout %d", config.DisplayTimeout)
+	}
+	if config.IdleTimeout < 0 {
+		return fmt.Errorf("invalid idle timeout %d", config.IdleTimeout)
+	}
+	if config.PushServerPort < 0 || config.PushServerPort > 65535 {
+		return fmt.Errorf("invalid push server port %d", config.PushServerPort)
+	}
+	if config.LogFullAction != "" && config.LogFullAction != models.LogFullOverwrite && config.LogFullAction != models.LogFullStop {
+		return fmt.Errorf("invalid log full action %q, valid value is %q or %q", config.LogFullAction, models.LogFullOverwrite, models.LogFullStop)
+	}
+	return nil
+}
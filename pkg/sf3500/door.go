@@ -0,0 +1,61 @@
+package sf3500
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+type DoorStatus string
+
+const (
+	DoorOpen       DoorStatus = "open"       // open door once, door is locked again after lock delay
+	DoorKeepOpen   DoorStatus = "keep_open"  // keep door open until status is changed
+	DoorKeepClosed DoorStatus = "keep_close" // keep door closed, verified users are not allowed to open the door
+	DoorNormal     DoorStatus = "normal"     // return to normal mode, door is opened by verified users
+)
+
+// Change door status of the machine
+func (dev *Sf3500) SetDoorStatus(status DoorStatus) (bool, error) {
+	command := cmds.SetDoorStatus{Command: "SetDoorStatus", Data: cmds.SetDoorStatusData{Status: string(status)}}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var result models.Response
+			if err := json.Unmarshal(response, &result); err == nil {
+				if result.ResultCode == 0 {
+					return true, nil
+				} else {
+					return false, fmt.Errorf("error code %d", result.ResultCode)
+				}
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
+
+// Open door once
+func (dev *Sf3500) OpenDoor() (bool, error) {
+	return dev.SetDoorStatus(DoorOpen)
+}
+
+// Keep door open until it is returned to normal
+func (dev *Sf3500) KeepDoorOpen() (bool, error) {
+	return dev.SetDoorStatus(DoorKeepOpen)
+}
+
+// Keep door closed until it is returned to normal
+func (dev *Sf3500) KeepDoorClosed() (bool, error) {
+	return dev.SetDoorStatus(DoorKeepClosed)
+}
+
+// Return door to normal mode
+func (dev *Sf3500) SetDoorNormal() (bool, error) {
+	return dev.SetDoorStatus(DoorNormal)
+}
@@ -10,6 +10,13 @@ import (
 	"github.com/masykur/absen/pkg/sf3500/models"
 )
 
+// Log filter to select log data from machine
+type LogFilter struct {
+	NewOnly   bool      // fetch unread logs only
+	BeginTime time.Time // first day of logs to fetch, zero value means 2000-01-01
+	EndTime   time.Time // last day of logs to fetch, zero value means today
+}
+
 func (dev *Sf3500) GetLog(packageId int, newLog int, beginTime time.Time, endTime time.Time, clearMark int) (int, []models.LogData, error) {
 	command := cmds.GetLog{Command: "GetLogData", Data: cmds.GetLogData{PackageID: packageId, NewLog: newLog, BeginTime: beginTime.Format("20060102"), EndTime: endTime.Format("20060102"), ClearMark: clearMark}}
 	if commandBytes, err := json.Marshal(command); err == nil {
@@ -31,3 +38,130 @@ func (dev *Sf3500) GetLog(packageId int, newLog int, beginTime time.Time, endTim
 		return 0, nil, err
 	}
 }
+
+// Fetch all log data matching the filter from machine.
+// When markRead is true, the machine clears the unread mark of the fetched logs.
+func (dev *Sf3500) FetchLog(filter LogFilter, markRead bool) ([]models.LogData, error) {
+	allLogs := make([]models.LogData, 0)
+	err := dev.streamLogs(filter, markRead, func(logs []models.LogData) error {
+		allLogs = append(allLogs, logs...)
+		return nil
+	})
+	return allLogs, err
+}
+
+func (dev *Sf3500) streamLogs(filter LogFilter, markRead bool, fn func(logs []models.LogData) error) error {
+	newLog := 0
+	if filter.NewOnly {
+		newLog = 1
+	}
+	clearMark := 0
+	if markRead {
+		clearMark = 1
+	}
+	beginTime := filter.BeginTime
+	if beginTime.IsZero() {
+		beginTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local)
+	}
+	endTime := filter.EndTime
+	if endTime.IsZero() {
+		endTime = time.Now()
+	}
+	guard := newPackageGuard()
+	packageId := 0
+	for {
+		var logs []models.LogData
+		var err error
+		if packageId, logs, err = dev.GetLog(packageId, newLog, beginTime, endTime, clearMark); err != nil {
+			return err
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		if more, err := guard.next(packageId); !more {
+			return err
+		}
+	}
+}
+
+// Maximum number of fetches waiting for unread logs to stop arriving before they are marked as read
+const MARK_ROUNDS int = 5
+
+// Logs marked as read by the machine but not persisted, the caller keeps them to avoid losing them
+type UnpersistedLogsError struct {
+	Logs []models.LogData
+	Err  error
+}
+
+func (e *UnpersistedLogsError) Error() string {
+	return fmt.Sprintf("persist log data failed, %d logs are marked as read without being persisted: %v", len(e.Logs), e.Err)
+}
+
+func (e *UnpersistedLogsError) Unwrap() error {
+	return e.Err
+}
+
+// Fetch unread log data matching the filter, pass them to persist function,
+// and mark them as read only after they are persisted successfully.
+// Unread logs are fetched without marking until no more logs arrive, then they are fetched again with mark.
+// The machine marks every unread log at once, so a log arriving in the moment between the last check and marking
+// is marked before it is persisted. Such logs are persisted right after marking,
+// when that fails they are returned in UnpersistedLogsError.
+// Returns number of persisted logs.
+func (dev *Sf3500) FetchLogAndMark(filter LogFilter, persist func(logs []models.LogData) error) (int, error) {
+	filter.NewOnly = true
+	persisted := make(map[string]bool)
+	count := 0
+	settled := false
+	for round := 0; round < MARK_ROUNDS; round++ {
+		logs, err := dev.FetchLog(filter, false)
+		if err != nil {
+			return count, err
+		}
+		fresh := unpersistedLogs(logs, persisted)
+		if len(fresh) == 0 {
+			settled = true
+			break
+		}
+		if err := persist(fresh); err != nil {
+			return count, fmt.Errorf("persist log data failed: %v", err)
+		}
+		for _, logData := range fresh {
+			persisted[logKey(logData)] = true
+		}
+		count += len(fresh)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	if !settled {
+		return count, fmt.Errorf("unread logs keep arriving, %d logs are persisted without being marked as read", count)
+	}
+	marked, err := dev.FetchLog(filter, true)
+	if err != nil {
+		return count, err
+	}
+	if remaining := unpersistedLogs(marked, persisted); len(remaining) > 0 {
+		if err := persist(remaining); err != nil {
+			return count, &UnpersistedLogsError{Logs: remaining, Err: err}
+		}
+		count += len(remaining)
+	}
+	return count, nil
+}
+
+// Logs not persisted yet
+func unpersistedLogs(logs []models.LogData, persisted map[string]bool) []models.LogData {
+	list := make([]models.LogData, 0)
+	for _, logData := range logs {
+		if !persisted[logKey(logData)] {
+			list = append(list, logData)
+		}
+	}
+	return list
+}
+
+// Identify log data by user, time, verify mode and IO mode
+func logKey(logData models.LogData) string {
+	return fmt.Sprintf("%s|%s|%s|%d", logData.UserID, logData.Time.String(), logData.VerifyMode, logData.IoMode)
+}
@@ -0,0 +1,158 @@
+package sf3500
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Machine keeping unread logs, new logs arrive before the numbered GetLogData call
+type unreadLogMachine struct {
+	mutex    sync.Mutex
+	unread   []models.LogData
+	arrivals map[int][]models.LogData
+	calls    int
+	events   []string
+}
+
+func (m *unreadLogMachine) handle(command fakeCommand) interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var data cmds.GetLogData
+	json.Unmarshal(command.Data, &data)
+	m.calls++
+	m.unread = append(m.unread, m.arrivals[m.calls]...)
+	logs := m.unread
+	if data.ClearMark == 1 {
+		m.events = append(m.events, "mark "+userIds(logs))
+		m.unread = nil
+	} else {
+		m.events = append(m.events, "fetch "+userIds(logs))
+	}
+	return models.LogResponse{Command: command.Command, ResultData: models.LogInfo{LogCount: len(logs), Logs: logs}}
+}
+
+func (m *unreadLogMachine) record(event string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = append(m.events, event)
+}
+
+func testLog(userId string) models.LogData {
+	return models.LogData{UserID: userId, Time: models.CustomTime(time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC)), VerifyMode: "FACE", IoMode: 1}
+}
+
+func userIds(logs []models.LogData) string {
+	ids := make([]string, 0, len(logs))
+	for _, logData := range logs {
+		ids = append(ids, logData.UserID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestFetchLogAndMark(t *testing.T) {
+	tests := []struct {
+		name      string
+		unread    []models.LogData
+		arrivals  map[int][]models.LogData
+		failAt    int // persist call failing, 0 for none
+		count     int
+		events    []string
+		unread2   int  // unread logs left on machine
+		errLogs   bool // UnpersistedLogsError expected
+		wantError bool
+	}{
+		{
+			name:   "no unread logs",
+			events: []string{"fetch "},
+		},
+		{
+			name:   "persisted before marked",
+			unread: []models.LogData{testLog("1"), testLog("2")},
+			count:  2,
+			events: []string{"fetch 1,2", "persist 1,2", "fetch 1,2", "mark 1,2"},
+		},
+		{
+			name:     "log arrives after first fetch",
+			unread:   []models.LogData{testLog("1")},
+			arrivals: map[int][]models.LogData{2: {testLog("2")}},
+			count:    2,
+			events:   []string{"fetch 1", "persist 1", "fetch 1,2", "persist 2", "fetch 1,2", "mark 1,2"},
+		},
+		{
+			name:     "log arrives before marking",
+			unread:   []models.LogData{testLog("1")},
+			arrivals: map[int][]models.LogData{3: {testLog("2")}},
+			count:    2,
+			events:   []string{"fetch 1", "persist 1", "fetch 1", "mark 1,2", "persist 2"},
+		},
+		{
+			name:      "persist fails, nothing is marked",
+			unread:    []models.LogData{testLog("1")},
+			failAt:    1,
+			events:    []string{"fetch 1", "persist 1"},
+			unread2:   1,
+			wantError: true,
+		},
+		{
+			name:      "persist of logs arrived before marking fails",
+			unread:    []models.LogData{testLog("1")},
+			arrivals:  map[int][]models.LogData{3: {testLog("2")}},
+			failAt:    2,
+			count:     1,
+			events:    []string{"fetch 1", "persist 1", "fetch 1", "mark 1,2", "persist 2"},
+			errLogs:   true,
+			wantError: true,
+		},
+		{
+			name:   "logs keep arriving",
+			unread: []models.LogData{testLog("1")},
+			arrivals: map[int][]models.LogData{2: {testLog("2")}, 3: {testLog("3")}, 4: {testLog("4")},
+				5: {testLog("5")}},
+			count: 5,
+			events: []string{"fetch 1", "persist 1", "fetch 1,2", "persist 2", "fetch 1,2,3", "persist 3",
+				"fetch 1,2,3,4", "persist 4", "fetch 1,2,3,4,5", "persist 5"},
+			unread2:   5,
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine := &unreadLogMachine{unread: test.unread, arrivals: test.arrivals}
+			dev := newFakeMachine(t, machine.handle)
+			persists := 0
+			count, err := dev.FetchLogAndMark(LogFilter{}, func(logs []models.LogData) error {
+				persists++
+				machine.record("persist " + userIds(logs))
+				if persists == test.failAt {
+					return fmt.Errorf("disk full")
+				}
+				return nil
+			})
+			if (err != nil) != test.wantError {
+				t.Fatalf("error = %v, want error %v", err, test.wantError)
+			}
+			var unpersisted *UnpersistedLogsError
+			if errors.As(err, &unpersisted) != test.errLogs {
+				t.Fatalf("error = %v, want UnpersistedLogsError %v", err, test.errLogs)
+			}
+			if count != test.count {
+				t.Errorf("count = %d, want %d", count, test.count)
+			}
+			if !reflect.DeepEqual(machine.events, test.events) {
+				t.Errorf("events = %q, want %q", machine.events, test.events)
+			}
+			if len(machine.unread) != test.unread2 {
+				t.Errorf("unread logs = %d, want %d", len(machine.unread), test.unread2)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package sf3500
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/masykur/absen/pkg/sf3500/models"
 )
@@ -12,7 +13,13 @@ func (dev *Sf3500) GetDeviceInfo() (models.DeviceInfo, error) {
 		var device models.DeviceResponse
 		data := []byte(response)
 		if err := json.Unmarshal(data, &device); err == nil {
-			return device.ResultData, nil
+			if device.ResultCode == 0 {
+				dev.maxBufferLen = device.ResultData.MaximumBufferLength
+				dev.infoQueried = true
+				return device.ResultData, nil
+			} else {
+				return models.DeviceInfo{}, fmt.Errorf("error code %d", device.ResultCode)
+			}
 		} else {
 			return models.DeviceInfo{}, err
 		}
@@ -0,0 +1,24 @@
+package models
+
+type DeviceConfigResponse struct {
+	Command    string       `json:"cmd"`
+	ResultCode int          `json:"result_code"`
+	ResultData DeviceConfig `json:"result_data"`
+}
+
+// Log full behaviour
+const (
+	LogFullOverwrite = "overwrite" // overwrite oldest logs when log storage is full
+	LogFullStop      = "stop"      // stop recording logs when log storage is full
+)
+
+type DeviceConfig struct {
+	VerifyMode        string `json:"verifyMode"`
+	Volume            int    `json:"volume"`
+	Language          string `json:"language"`
+	DisplayTimeout    int    `json:"displayTimeout"` // seconds before screen is turned off
+	IdleTimeout       int    `json:"idleTimeout"`    // seconds before machine returns to main screen
+	PushServerAddress string `json:"serverHost"`
+	PushServerPort    int    `json:"serverPort"`
+	LogFullAction     string `json:"logFullAction"`
+}
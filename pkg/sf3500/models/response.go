@@ -0,0 +1,6 @@
+package models
+
+type Response struct {
+	Command    string `json:"cmd"`
+	ResultCode int    `json:"result_code"`
+}
@@ -0,0 +1,11 @@
+package models
+
+type TimeResponse struct {
+	Command    string     `json:"cmd"`
+	ResultCode int        `json:"result_code"`
+	ResultData DeviceTime `json:"result_data"`
+}
+
+type DeviceTime struct {
+	Time string `json:"time"`
+}
@@ -0,0 +1,25 @@
+package models
+
+type TimeTableResponse struct {
+	Command    string    `json:"cmd"`
+	ResultCode int       `json:"result_code"`
+	ResultData TimeTable `json:"result_data"`
+}
+
+// Access schedule of the machine. Time groups are assigned to users per weekday
+// through User.TimeGroups, each time group allows access on its time periods.
+type TimeTable struct {
+	Periods []TimePeriod `json:"timePeriods"`
+	Groups  []TimeGroup  `json:"timeGroups"`
+}
+
+type TimePeriod struct {
+	ID   int    `json:"id"`
+	Time string `json:"time"` // HHMM-HHMM, ex. 0800-1700
+}
+
+type TimeGroup struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Periods []int  `json:"periods"` // time period ids
+}
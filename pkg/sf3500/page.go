@@ -0,0 +1,116 @@
+package sf3500
+
+import (
+	"fmt"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Maximum number of packages fetched in a single pagination,
+// protects against machine that never returns package id 0
+const MAX_PACKAGES int = 100000
+
+// Track package ids returned by machine and detect pagination loop
+type packageGuard struct {
+	seen map[int]bool
+}
+
+func newPackageGuard() *packageGuard {
+	return &packageGuard{seen: make(map[int]bool)}
+}
+
+// Check next package id, returns true if there are more packages to fetch
+func (guard *packageGuard) next(packageId int) (bool, error) {
+	if packageId == 0 {
+		return false, nil
+	}
+	if guard.seen[packageId] {
+		return false, fmt.Errorf("machine returned package id %d more than once", packageId)
+	}
+	if len(guard.seen) >= MAX_PACKAGES {
+		return false, fmt.Errorf("too many packages, more than %d", MAX_PACKAGES)
+	}
+	guard.seen[packageId] = true
+	return true, nil
+}
+
+// Pass every package of registered users to fn, stop when fn returns error
+func (dev *Sf3500) StreamUsers(fn func(users []models.User) error) error {
+	guard := newPackageGuard()
+	packageId := 0
+	for {
+		var users []models.User
+		var err error
+		if packageId, users, err = dev.GetUserList(packageId); err != nil {
+			return err
+		}
+		if err := fn(users); err != nil {
+			return err
+		}
+		if more, err := guard.next(packageId); !more {
+			return err
+		}
+	}
+}
+
+// Retrieve all registered users from machine
+func (dev *Sf3500) AllUsers() ([]models.User, error) {
+	allUsers := make([]models.User, 0)
+	err := dev.StreamUsers(func(users []models.User) error {
+		allUsers = append(allUsers, users...)
+		return nil
+	})
+	return allUsers, err
+}
+
+// Pass every package of users information to fn, stop when fn returns error.
+// User ids are sent in batches so each command fits in machine buffer length.
+func (dev *Sf3500) StreamUsersInfo(fn func(users []models.User) error, userIds ...string) error {
+	// use large package id to reserve space for package id in the following requests
+	emptyCommand := cmds.GetUserInfo{Command: "GetUserInfo", Data: cmds.GetUserInfoData{PackageID: 1 << 31, UsersId: []string{}}}
+	batches, err := dev.splitBatches(emptyCommand, idSizes(userIds))
+	if err != nil {
+		return err
+	}
+	for _, batch := range batches {
+		guard := newPackageGuard()
+		packageId := 0
+		for {
+			var users []models.User
+			if packageId, users, err = dev.GetUserInfo(packageId, userIds[batch[0]:batch[1]]...); err != nil {
+				return err
+			}
+			if err := fn(users); err != nil {
+				return err
+			}
+			if more, err := guard.next(packageId); !more {
+				if err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Retrieve information of users including card, fingerprint templates and face data
+func (dev *Sf3500) UsersInfo(userIds ...string) ([]models.User, error) {
+	allUsers := make([]models.User, 0)
+	err := dev.StreamUsersInfo(func(users []models.User) error {
+		allUsers = append(allUsers, users...)
+		return nil
+	}, userIds...)
+	return allUsers, err
+}
+
+// Pass every package of log data matching the filter to fn, stop when fn returns error
+func (dev *Sf3500) StreamLogs(filter LogFilter, fn func(logs []models.LogData) error) error {
+	return dev.streamLogs(filter, false, fn)
+}
+
+// Retrieve all log data matching the filter from machine
+func (dev *Sf3500) AllLogs(filter LogFilter) ([]models.LogData, error) {
+	return dev.FetchLog(filter, false)
+}
@@ -0,0 +1,158 @@
+package sf3500
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Detect image format from its magic bytes, returns file extension without dot
+// or "bin" if the format is unknown
+func DetectImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return "jpg"
+	case bytes.HasPrefix(data, []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a}):
+		return "png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "bmp"
+	case len(data) > 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "webp"
+	default:
+		return "bin"
+	}
+}
+
+// Decode base64 encoded photo, data URI prefix (data:image/jpeg;base64,) is removed if exists
+func DecodePhoto(encoded string) ([]byte, error) {
+	if strings.HasPrefix(encoded, "data:") {
+		if i := strings.Index(encoded, ","); i >= 0 {
+			encoded = encoded[i+1:]
+		}
+	}
+	encoded = strings.TrimSpace(encoded)
+	if data, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
+
+// Maximum number of different photos saved with the same name
+const MAX_PHOTO_SEQUENCE int = 1000
+
+// Decode base64 encoded photo and save it to directory,
+// file extension is detected from image format.
+// Existing file is never overwritten, a different photo with the same name is saved with sequence suffix, e.g. name_2.jpg,
+// and the same photo saved before is not saved again.
+// Returns path of the saved file.
+func SavePhoto(dir string, name string, encoded string) (string, error) {
+	data, err := DecodePhoto(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid photo data: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	extension := DetectImageFormat(data)
+	for sequence := 1; sequence <= MAX_PHOTO_SEQUENCE; sequence++ {
+		path := filepath.Join(dir, name+"."+extension)
+		if sequence > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s_%d.%s", name, sequence, extension))
+		}
+		saved, err := createPhoto(path, data)
+		if saved {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		// the same photo is saved before, e.g. log fetched again
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("too many photos named %s", name)
+}
+
+// Write photo to new file, fs.ErrExist is returned when the file exists
+func createPhoto(path string, data []byte) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+// Build photo file name from device id, user id and timestamp,
+// SavePhoto appends sequence to names of different photos taken in the same second
+func PhotoFileName(deviceId string, userId string, t time.Time) string {
+	return fmt.Sprintf("%s_%s_%s", safeFileName(deviceId), safeFileName(userId), t.Format("20060102150405"))
+}
+
+// Save log photos to directory and replace inline photo data with the file path
+func ExportLogPhotos(dir string, deviceId string, logs []models.LogData) error {
+	for i := range logs {
+		if logs[i].LogPhoto == "" {
+			continue
+		}
+		path, err := SavePhoto(dir, PhotoFileName(deviceId, logs[i].UserID, logs[i].Time.Time()), logs[i].LogPhoto)
+		if err != nil {
+			return fmt.Errorf("export photo of user %s failed: %v", logs[i].UserID, err)
+		}
+		logs[i].LogPhoto = path
+	}
+	return nil
+}
+
+// Save user photos and faces to directory and replace inline data with the file path,
+// t is the timestamp used in the file names
+func ExportUserPhotos(dir string, deviceId string, t time.Time, users []models.User) error {
+	for i := range users {
+		name := PhotoFileName(deviceId, users[i].UserID, t)
+		if users[i].Photo != "" {
+			path, err := SavePhoto(dir, name+"_photo", users[i].Photo)
+			if err != nil {
+				return fmt.Errorf("export photo of user %s failed: %v", users[i].UserID, err)
+			}
+			users[i].Photo = path
+		}
+		if users[i].Face != "" {
+			path, err := SavePhoto(dir, name+"_face", users[i].Face)
+			if err != nil {
+				return fmt.Errorf("export face of user %s failed: %v", users[i].UserID, err)
+			}
+			users[i].Face = path
+		}
+	}
+	return nil
+}
+
+// Replace characters that are not allowed in file name
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
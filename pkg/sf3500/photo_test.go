@@ -0,0 +1,31 @@
+package sf3500
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavePhotoKeepsPhotosWithSameName(t *testing.T) {
+	dir := t.TempDir()
+	first := base64.StdEncoding.EncodeToString([]byte{0xff, 0xd8, 0xff, 1})
+	second := base64.StdEncoding.EncodeToString([]byte{0xff, 0xd8, 0xff, 2})
+	tests := []struct {
+		photo string
+		path  string
+	}{
+		{first, "dev_1_20220102080000.jpg"},
+		{second, "dev_1_20220102080000_2.jpg"},
+		{first, "dev_1_20220102080000.jpg"},
+		{second, "dev_1_20220102080000_2.jpg"},
+	}
+	for _, test := range tests {
+		path, err := SavePhoto(dir, "dev_1_20220102080000", test.photo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != filepath.Join(dir, test.path) {
+			t.Errorf("path = %s, want %s", path, test.path)
+		}
+	}
+}
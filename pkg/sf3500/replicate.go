@@ -0,0 +1,58 @@
+package sf3500
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Result of replicating users to a target machine
+type ReplicationResult struct {
+	Address string `json:"address"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Enroll users to target machines concurrently,
+// addresses are in host:port format. Returns result of each target in the same order.
+func ReplicateUsers(users []models.User, addresses []string, timeout time.Duration) []ReplicationResult {
+	results := make([]ReplicationResult, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			results[i] = ReplicationResult{Address: address}
+			target := new(Sf3500)
+			if ok, err := target.Connect(address, timeout); !ok {
+				results[i].Error = err.Error()
+				return
+			}
+			defer target.Close()
+			if ok, err := target.SetUserInfo(users...); !ok {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Success = true
+		}(i, address)
+	}
+	wg.Wait()
+	return results
+}
+
+// Copy users including face, fingerprints, card and password from this machine to target machines
+func (dev *Sf3500) CopyUsersTo(addresses []string, timeout time.Duration, userIds ...string) ([]ReplicationResult, error) {
+	if len(userIds) == 0 {
+		return nil, fmt.Errorf("no user to copy")
+	}
+	users, err := dev.UsersInfo(userIds...)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("users not found")
+	}
+	return ReplicateUsers(users, addresses, timeout), nil
+}
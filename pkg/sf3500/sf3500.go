@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"time"
 )
@@ -12,62 +13,88 @@ const (
 	PROTOCOL_KEY        uint32 = 404232216
 	HEADER_SIZE         int    = 32
 	RECEIVE_BUFFER_SIZE int    = 409600
+	MAX_RESPONSE_SIZE   int    = 64 * 1024 * 1024 // sanity limit of response length declared in header
+	DEFAULT_BUFFER_SIZE int    = 64 * 1024        // command length used for batching when machine doesn't report its buffer length
 )
 
 type Sf3500 struct {
-	conn *net.TCPConn
+	conn         *net.TCPConn
+	timeout      time.Duration
+	maxBufferLen int    // maximum command length accepted by machine, 0 if not known yet
+	infoQueried  bool   // device info is read for maxBufferLen
+	protocolKey  uint32 // PROTOCOL_KEY when zero
 }
 
-func min(a int, b int) int {
-	if a < b {
-		return a
+// Use protocol key other than the default PROTOCOL_KEY, for machines configured with a different key
+func (dev *Sf3500) SetProtocolKey(key uint32) {
+	dev.protocolKey = key
+}
+
+func (dev *Sf3500) key() uint32 {
+	if dev.protocolKey != 0 {
+		return dev.protocolKey
 	}
-	return b
+	return PROTOCOL_KEY
 }
 
 // send single command
 func (dev *Sf3500) sendCommand(command []byte) ([]byte, error) {
 	commandLength := len(command)
+	if dev.maxBufferLen > 0 && commandLength > dev.maxBufferLen {
+		return nil, fmt.Errorf("command length %d exceeds machine buffer length %d", commandLength, dev.maxBufferLen)
+	}
+	// Request and response message
+	// byte[0..3]   = message length excluding header, little endian
+	// byte[4..7]   = protocol key, default 404232216
+	// byte[8..31]  = reserved
+	// byte[32..]   = json message, response is terminated by new line and null character
 	buffer := make([]byte, HEADER_SIZE, HEADER_SIZE+commandLength)
 	binary.LittleEndian.PutUint32(buffer[0:4], uint32(commandLength))
-	binary.LittleEndian.PutUint32(buffer[4:8], PROTOCOL_KEY)
+	binary.LittleEndian.PutUint32(buffer[4:8], dev.key())
 	buffer = append(buffer, command...)
-	dev.conn.Write(buffer)
-	responseHeader := make([]byte, HEADER_SIZE)
-	var responseLength int
-	if cnt, err := dev.conn.Read(responseHeader); err == nil {
-		if cnt != HEADER_SIZE {
-			return nil, fmt.Errorf("invalid response")
+	if dev.timeout > 0 {
+		if err := dev.conn.SetDeadline(time.Now().Add(dev.timeout)); err != nil {
+			return nil, err
 		}
-		responseLength = int(binary.LittleEndian.Uint32(responseHeader))
 	}
+	if _, err := dev.conn.Write(buffer); err != nil {
+		return nil, fmt.Errorf("send command to machine failed: %v", err)
+	}
+	responseHeader := make([]byte, HEADER_SIZE)
+	if _, err := io.ReadFull(dev.conn, responseHeader); err != nil {
+		return nil, fmt.Errorf("read response header failed: %v", err)
+	}
+	if key := binary.LittleEndian.Uint32(responseHeader[4:8]); key != dev.key() {
+		return nil, fmt.Errorf("invalid response protocol key %d", key)
+	}
+	responseLength := int(binary.LittleEndian.Uint32(responseHeader[0:4]))
 	if responseLength == 0 {
-		return nil, nil
+		return nil, fmt.Errorf("empty response")
+	}
+	if responseLength > MAX_RESPONSE_SIZE {
+		return nil, fmt.Errorf("response length %d exceeds maximum %d", responseLength, MAX_RESPONSE_SIZE)
+	}
+	response := make([]byte, responseLength)
+	if _, err := io.ReadFull(dev.conn, response); err != nil {
+		return nil, fmt.Errorf("read response failed, expected %d bytes: %v", responseLength, err)
 	}
-	responseBuffer := make([]byte, min(responseLength, 1460))
-	var stringBuffer bytes.Buffer
-	counting := 0
-	for {
-		counting++
-		if cnt, err := dev.conn.Read(responseBuffer); err == nil {
-			if cnt == 0 {
-				break
-			}
-			stringBuffer.Write(responseBuffer[:cnt])
-			if cnt == 1 && responseBuffer[0] == 0 {
-				break
-			}
-			if responseBuffer[cnt-2] == 10 && responseBuffer[cnt-1] == 0 {
-				break
-			}
-		} else {
-			return nil, nil
+	// remove new line and null terminator
+	return bytes.TrimRight(response, "\n\x00"), nil
+}
+
+// Obtain maximum command length accepted by machine,
+// the value is read from device info once and cached for the connection.
+// DEFAULT_BUFFER_SIZE is returned when machine reports 0.
+func (dev *Sf3500) MaxBufferLength() (int, error) {
+	if !dev.infoQueried {
+		if _, err := dev.GetDeviceInfo(); err != nil {
+			return 0, err
 		}
 	}
-	// fmt.Println(stringBuffer.Len())
-	// fmt.Println(string(stringBuffer.Bytes()[:min(80, stringBuffer.Len())]))
-	// fmt.Println(string(stringBuffer.Bytes()[stringBuffer.Len()-min(80, stringBuffer.Len()):]))
-	return stringBuffer.Bytes()[:stringBuffer.Len()-2], nil
+	if dev.maxBufferLen <= 0 {
+		return DEFAULT_BUFFER_SIZE, nil
+	}
+	return dev.maxBufferLen, nil
 }
 
 // Open connection
@@ -79,6 +106,9 @@ func (dev *Sf3500) Connect(address string, timeout time.Duration) (bool, error)
 	}
 	var ok bool
 	if dev.conn, ok = conn.(*net.TCPConn); ok {
+		dev.timeout = timeout
+		dev.maxBufferLen = 0
+		dev.infoQueried = false
 		return true, nil
 	}
 	return false, fmt.Errorf("connection failed")
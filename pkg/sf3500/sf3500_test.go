@@ -0,0 +1,139 @@
+package sf3500
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Command received by fake machine
+type fakeCommand struct {
+	Command string          `json:"cmd"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Start a machine answering commands by handle and connect to it
+func newFakeMachine(t *testing.T, handle func(command fakeCommand) interface{}) *Sf3500 {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header := make([]byte, HEADER_SIZE)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			body := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+			var command fakeCommand
+			if err := json.Unmarshal(body, &command); err != nil {
+				t.Errorf("invalid command %s: %v", body, err)
+				return
+			}
+			response, _ := json.Marshal(handle(command))
+			response = append(response, '\n', 0)
+			binary.LittleEndian.PutUint32(header[0:4], uint32(len(response)))
+			binary.LittleEndian.PutUint32(header[4:8], PROTOCOL_KEY)
+			if _, err := conn.Write(append(header, response...)); err != nil {
+				return
+			}
+		}
+	}()
+	dev := &Sf3500{}
+	if _, err := dev.Connect(listener.Addr().String(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dev.Close()
+		listener.Close()
+	})
+	return dev
+}
+
+func TestSendCommandRejectsWrongProtocolKey(t *testing.T) {
+	dev := newFakeMachine(t, func(command fakeCommand) interface{} {
+		return map[string]interface{}{"cmd": command.Command, "result_code": 0}
+	})
+	dev.SetProtocolKey(PROTOCOL_KEY + 1)
+	if _, err := dev.sendCommand([]byte(`{"cmd":"GetDeviceInfo"}`)); err == nil {
+		t.Fatal("expected error of response with default protocol key")
+	}
+}
+
+func TestMaxBufferLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		reported int
+		want     int
+	}{
+		{"reported by machine", 4096, 4096},
+		{"not reported by machine", 0, DEFAULT_BUFFER_SIZE},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries := 0
+			dev := newFakeMachine(t, func(command fakeCommand) interface{} {
+				queries++
+				return models.DeviceResponse{Command: command.Command, ResultData: models.DeviceInfo{MaximumBufferLength: test.reported}}
+			})
+			for i := 0; i < 3; i++ {
+				length, err := dev.MaxBufferLength()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if length != test.want {
+					t.Errorf("length = %d, want %d", length, test.want)
+				}
+			}
+			if queries != 1 {
+				t.Errorf("device info is queried %d times, want once", queries)
+			}
+		})
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	// empty command {"ids":[]} is 10 bytes, items after the first one need a comma
+	empty := map[string][]string{"ids": {}}
+	tests := []struct {
+		name     string
+		reported int
+		sizes    []int
+		batches  [][2]int
+		wantErr  bool
+	}{
+		{"fit in one command", 30, []int{5, 5, 5}, [][2]int{{0, 3}}, false},
+		{"split by buffer length", 22, []int{5, 5, 5}, [][2]int{{0, 2}, {2, 3}}, false},
+		{"item exceeds reported length", 20, []int{5, 15}, nil, true},
+		{"large item without reported length", 0, []int{5, DEFAULT_BUFFER_SIZE, 5}, [][2]int{{0, 1}, {1, 2}, {2, 3}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dev := newFakeMachine(t, func(command fakeCommand) interface{} {
+				return models.DeviceResponse{Command: command.Command, ResultData: models.DeviceInfo{MaximumBufferLength: test.reported}}
+			})
+			batches, err := dev.splitBatches(empty, test.sizes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(batches, test.batches) {
+				t.Errorf("batches = %v, want %v", batches, test.batches)
+			}
+		})
+	}
+}
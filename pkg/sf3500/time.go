@@ -0,0 +1,58 @@
+package sf3500
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+const timeLayout = "20060102150405"
+
+// Obtain current date and time from machine
+func (dev *Sf3500) GetDateTime() (time.Time, error) {
+	command := cmds.GetDeviceTime{Command: "GetDeviceTime"}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var deviceTime models.TimeResponse
+			if err := json.Unmarshal(response, &deviceTime); err == nil {
+				if deviceTime.ResultCode == 0 {
+					return time.ParseInLocation(timeLayout, deviceTime.ResultData.Time, time.Local)
+				} else {
+					return time.Time{}, fmt.Errorf("error code %d", deviceTime.ResultCode)
+				}
+			} else {
+				return time.Time{}, err
+			}
+		} else {
+			return time.Time{}, err
+		}
+	} else {
+		return time.Time{}, err
+	}
+}
+
+// Set date and time to machine
+func (dev *Sf3500) SetDateTime(t time.Time) (bool, error) {
+	command := cmds.SetDeviceTime{Command: "SetDeviceTime", Data: cmds.SetDeviceTimeData{Time: t.In(time.Local).Format(timeLayout)}}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var result models.Response
+			if err := json.Unmarshal(response, &result); err == nil {
+				if result.ResultCode == 0 {
+					return true, nil
+				} else {
+					return false, fmt.Errorf("error code %d", result.ResultCode)
+				}
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
@@ -0,0 +1,147 @@
+package sf3500
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Obtain time periods and time groups from machine
+func (dev *Sf3500) GetTimeTable() (models.TimeTable, error) {
+	command := cmds.GetTimeTable{Command: "GetTimeGroup"}
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var timeTable models.TimeTableResponse
+			if err := json.Unmarshal(response, &timeTable); err == nil {
+				if timeTable.ResultCode == 0 {
+					return timeTable.ResultData, nil
+				} else {
+					return models.TimeTable{}, fmt.Errorf("error code %d", timeTable.ResultCode)
+				}
+			} else {
+				return models.TimeTable{}, err
+			}
+		} else {
+			return models.TimeTable{}, err
+		}
+	} else {
+		return models.TimeTable{}, err
+	}
+}
+
+// Write time periods and time groups to machine.
+// The time table is validated before sent, time periods are written before time groups.
+func (dev *Sf3500) SetTimeTable(timeTable models.TimeTable) (bool, error) {
+	if err := ValidateTimeTable(timeTable); err != nil {
+		return false, err
+	}
+	if ok, err := dev.setTimeTable(cmds.SetTimePeriod{Command: "SetTimePeriod", Data: cmds.SetTimePeriodData{Periods: timeTable.Periods}}); !ok {
+		return false, err
+	}
+	return dev.setTimeTable(cmds.SetTimeGroup{Command: "SetTimeGroup", Data: cmds.SetTimeGroupData{Groups: timeTable.Groups}})
+}
+
+func (dev *Sf3500) setTimeTable(command interface{}) (bool, error) {
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var result models.Response
+			if err := json.Unmarshal(response, &result); err == nil {
+				if result.ResultCode == 0 {
+					return true, nil
+				} else {
+					return false, fmt.Errorf("error code %d", result.ResultCode)
+				}
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
+
+// Validate time table, time periods must be in HHMM-HHMM format,
+// time groups must refer to existing time periods and the periods of a group must not overlap
+func ValidateTimeTable(timeTable models.TimeTable) error {
+	type timeRange struct {
+		id    int
+		start int
+		end   int
+	}
+	periods := make(map[int]timeRange, len(timeTable.Periods))
+	for _, period := range timeTable.Periods {
+		if _, exists := periods[period.ID]; exists {
+			return fmt.Errorf("duplicate time period id %d", period.ID)
+		}
+		start, end, err := ParseTimeRange(period.Time)
+		if err != nil {
+			return fmt.Errorf("time period %d: %v", period.ID, err)
+		}
+		periods[period.ID] = timeRange{id: period.ID, start: start, end: end}
+	}
+	groups := make(map[int]bool, len(timeTable.Groups))
+	for _, group := range timeTable.Groups {
+		if groups[group.ID] {
+			return fmt.Errorf("duplicate time group id %d", group.ID)
+		}
+		groups[group.ID] = true
+		ranges := make([]timeRange, 0, len(group.Periods))
+		for _, id := range group.Periods {
+			period, exists := periods[id]
+			if !exists {
+				return fmt.Errorf("time group %d: time period %d does not exist", group.ID, id)
+			}
+			ranges = append(ranges, period)
+		}
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+		for i := 1; i < len(ranges); i++ {
+			if ranges[i].start < ranges[i-1].end {
+				return fmt.Errorf("time group %d: time period %d overlaps time period %d", group.ID, ranges[i].id, ranges[i-1].id)
+			}
+		}
+	}
+	return nil
+}
+
+// Parse time range in HHMM-HHMM format, returns start and end in minutes of day.
+// End time 2400 is allowed to specify end of day.
+func ParseTimeRange(value string) (int, int, error) {
+	if len(value) != 9 || value[4] != '-' {
+		return 0, 0, fmt.Errorf("invalid time range %q, expected format is HHMM-HHMM", value)
+	}
+	start, err := parseTimeOfDay(value[:4], false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time range %q: %v", value, err)
+	}
+	end, err := parseTimeOfDay(value[5:], true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time range %q: %v", value, err)
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("invalid time range %q, start time must be before end time", value)
+	}
+	return start, end, nil
+}
+
+// Parse time in HHMM format, returns minutes of day
+func parseTimeOfDay(value string, allowEndOfDay bool) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	hour := number / 100
+	minute := number % 100
+	if allowEndOfDay && hour == 24 && minute == 0 {
+		return 24 * 60, nil
+	}
+	if hour > 23 || minute > 59 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -39,7 +39,6 @@ func (dev *Sf3500) GetUserInfo(packageId int, userIds ...string) (int, []models.
 					return 0, nil, fmt.Errorf("error code %d", userList.ResultCode)
 				}
 			} else {
-				fmt.Println("JSON error", err)
 				return 0, nil, err
 			}
 		} else {
@@ -49,3 +48,117 @@ func (dev *Sf3500) GetUserInfo(packageId int, userIds ...string) (int, []models.
 		return 0, nil, err
 	}
 }
+
+// Enroll new users or update existing users in the machine,
+// including card, password, validity dates, time groups, fingerprint templates and face data.
+// Users are sent in batches so each command fits in machine buffer length.
+func (dev *Sf3500) SetUserInfo(users ...models.User) (bool, error) {
+	if len(users) == 0 {
+		return false, fmt.Errorf("no user to enroll")
+	}
+	sizes := make([]int, len(users))
+	for i, user := range users {
+		if userBytes, err := json.Marshal(user); err == nil {
+			sizes[i] = len(userBytes)
+		} else {
+			return false, err
+		}
+	}
+	batches, err := dev.splitBatches(cmds.SetUserInfo{Command: "SetUserInfo", Data: cmds.SetUserInfoData{Users: []models.User{}}}, sizes)
+	if err != nil {
+		return false, err
+	}
+	for _, batch := range batches {
+		command := cmds.SetUserInfo{Command: "SetUserInfo", Data: cmds.SetUserInfoData{Users: users[batch[0]:batch[1]]}}
+		if ok, err := dev.sendUserCommand(command); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Remove users from the machine.
+// User ids are sent in batches so each command fits in machine buffer length.
+func (dev *Sf3500) DeleteUser(userIds ...string) (bool, error) {
+	if len(userIds) == 0 {
+		return false, fmt.Errorf("no user to delete")
+	}
+	batches, err := dev.splitBatches(cmds.DeleteUser{Command: "DeleteUser", Data: cmds.DeleteUserData{UsersId: []string{}}}, idSizes(userIds))
+	if err != nil {
+		return false, err
+	}
+	for _, batch := range batches {
+		command := cmds.DeleteUser{Command: "DeleteUser", Data: cmds.DeleteUserData{UsersId: userIds[batch[0]:batch[1]]}}
+		if ok, err := dev.sendUserCommand(command); !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (dev *Sf3500) sendUserCommand(command interface{}) (bool, error) {
+	if commandBytes, err := json.Marshal(command); err == nil {
+		if response, err := dev.sendCommand(commandBytes); err == nil {
+			var result models.Response
+			if err := json.Unmarshal(response, &result); err == nil {
+				if result.ResultCode == 0 {
+					return true, nil
+				} else {
+					return false, fmt.Errorf("error code %d", result.ResultCode)
+				}
+			} else {
+				return false, err
+			}
+		} else {
+			return false, err
+		}
+	} else {
+		return false, err
+	}
+}
+
+// Encoded json length of each id
+func idSizes(ids []string) []int {
+	sizes := make([]int, len(ids))
+	for i, id := range ids {
+		idBytes, _ := json.Marshal(id)
+		sizes[i] = len(idBytes)
+	}
+	return sizes
+}
+
+// Split items into batches so each command fits in machine buffer length.
+// emptyCommand is the command without any item, sizes are encoded json length of each item.
+// Returns list of [begin, end) index of the items.
+func (dev *Sf3500) splitBatches(emptyCommand interface{}, sizes []int) ([][2]int, error) {
+	maxLength, err := dev.MaxBufferLength()
+	if err != nil {
+		return nil, err
+	}
+	commandBytes, err := json.Marshal(emptyCommand)
+	if err != nil {
+		return nil, err
+	}
+	overhead := len(commandBytes)
+	batches := make([][2]int, 0)
+	begin := 0
+	length := overhead
+	for i, size := range sizes {
+		// machine not reporting its buffer length receives larger items in their own command
+		if overhead+size > maxLength && dev.maxBufferLen > 0 {
+			return nil, fmt.Errorf("item %d length %d exceeds machine buffer length %d", i, size, maxLength)
+		}
+		// items are separated by comma
+		if i > begin {
+			size++
+		}
+		if length+size > maxLength && i > begin {
+			batches = append(batches, [2]int{begin, i})
+			begin = i
+			length = overhead + sizes[i]
+		} else {
+			length += size
+		}
+	}
+	return append(batches, [2]int{begin, len(sizes)}), nil
+}
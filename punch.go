@@ -0,0 +1,74 @@
+package absen
+
+import (
+	"strings"
+	"time"
+)
+
+// How the subject was identified
+type Credential string
+
+const (
+	CredentialUnknown     Credential = "unknown"
+	CredentialFingerprint Credential = "fingerprint"
+	CredentialCard        Credential = "card"
+	CredentialFace        Credential = "face"
+	CredentialPalm        Credential = "palm"
+	CredentialPassword    Credential = "password"
+	CredentialButton      Credential = "button" // exit button, there is no subject
+)
+
+type Direction string
+
+const (
+	DirectionUnknown Direction = "unknown"
+	DirectionIn      Direction = "in"
+	DirectionOut     Direction = "out"
+)
+
+type Result string
+
+const (
+	ResultUnknown Result = "unknown"
+	ResultGranted Result = "granted"
+	ResultDenied  Result = "denied"
+)
+
+// Attendance or access log normalized from machine log
+type Punch struct {
+	DeviceID   string      `json:"deviceId"`
+	SubjectID  string      `json:"subjectId"` // user id, or facility code followed by 5 digits card number on card only machines
+	Credential Credential  `json:"credential"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Direction  Direction   `json:"direction"`
+	Result     Result      `json:"result"`
+	Raw        interface{} `json:"-"` // log of the driver
+}
+
+// Credential from verify mode text of SF3500
+func parseCredential(verifyMode string) Credential {
+	mode := strings.ToLower(verifyMode)
+	switch {
+	case strings.Contains(mode, "face"):
+		return CredentialFace
+	case strings.Contains(mode, "fp"), strings.Contains(mode, "finger"):
+		return CredentialFingerprint
+	case strings.Contains(mode, "card"):
+		return CredentialCard
+	case strings.Contains(mode, "palm"), strings.Contains(mode, "pv"):
+		return CredentialPalm
+	case strings.Contains(mode, "pwd"), strings.Contains(mode, "pass"):
+		return CredentialPassword
+	}
+	return CredentialUnknown
+}
+
+func parseDirection(inOut string) Direction {
+	switch strings.ToLower(inOut) {
+	case "in":
+		return DirectionIn
+	case "out":
+		return DirectionOut
+	}
+	return DirectionUnknown
+}
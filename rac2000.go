@@ -0,0 +1,168 @@
+package absen
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/masykur/absen/pkg/rac2000"
+)
+
+// RAC2000 adapter, implements Clock, LogSource and UserStore.
+// Users of the machine are cards, user id is 3 digits facility code followed by 5 digits card number.
+type Rac2000Device struct {
+	dev *rac2000.Rac2000
+	id  string
+}
+
+const rac2000Port = 4660
+
+func init() {
+	Register(Driver{
+		Model:       "rac2000",
+		Description: "RECO RAC2000 and AC2200PC access controller",
+		DefaultPort: rac2000Port,
+		Params:      []Param{ParamMachineID},
+		Open: func(options Options) (Device, error) {
+			device, err := OpenRac2000(options)
+			if err != nil {
+				return nil, err
+			}
+			return device, nil
+		}})
+}
+
+var (
+	_ Clock     = (*Rac2000Device)(nil)
+	_ LogSource = (*Rac2000Device)(nil)
+	_ UserStore = (*Rac2000Device)(nil)
+)
+
+// Connect to RAC2000 machine, default port is 4660
+func OpenRac2000(options Options) (*Rac2000Device, error) {
+	dev := new(rac2000.Rac2000)
+	if ok, err := dev.Connect(AddressWithPort(options.Address, rac2000Port), options.MachineID, options.timeout()); !ok {
+		return nil, err
+	}
+	device := &Rac2000Device{dev: dev, id: options.DeviceID}
+	if device.id == "" {
+		device.id = strconv.Itoa(int(options.MachineID))
+	}
+	return device, nil
+}
+
+// Underlying driver, for features not covered by the common interfaces
+func (d *Rac2000Device) Driver() *rac2000.Rac2000 {
+	return d.dev
+}
+
+func (d *Rac2000Device) Model() string {
+	return "rac2000"
+}
+
+func (d *Rac2000Device) ID() string {
+	return d.id
+}
+
+func (d *Rac2000Device) Capabilities() Capability {
+	return CapClock | CapLogs | CapUsers | CapDeleteUsers | CapCard | CapPassword
+}
+
+func (d *Rac2000Device) Close() error {
+	d.dev.Close()
+	return nil
+}
+
+func (d *Rac2000Device) Time() (time.Time, error) {
+	return d.dev.GetDateTime()
+}
+
+func (d *Rac2000Device) SetTime(t time.Time) error {
+	ok, err := d.dev.SetDateTime(t)
+	return DriverError(ok, err, "set time")
+}
+
+// Read logs, the machine removes logs once they are read.
+// Logs fetched before an error are returned with the error.
+func (d *Rac2000Device) Punches() ([]Punch, error) {
+	logs, err := d.dev.FetchLog()
+	punches := make([]Punch, 0, len(logs))
+	for _, logData := range logs {
+		punch := Punch{
+			DeviceID:   d.id,
+			SubjectID:  formatCard(logData.CardFacilityCode, logData.CardId),
+			Credential: CredentialCard,
+			Timestamp:  logData.DateTime,
+			Direction:  DirectionUnknown,
+			Result:     ResultGranted,
+			Raw:        logData}
+		if punch.SubjectID == "" {
+			punch.Credential = CredentialButton
+		}
+		if logData.Event != 0 {
+			punch.Result = ResultDenied
+		}
+		punches = append(punches, punch)
+	}
+	return punches, err
+}
+
+func (d *Rac2000Device) Users() ([]User, error) {
+	cards, err := d.dev.GetCards()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(cards))
+	for _, card := range cards {
+		user := User{ID: formatCard(card.FacilityCode, card.Id), Raw: card}
+		user.Card = user.ID
+		if card.Password != 0 {
+			user.Password = strconv.FormatUint(uint64(card.Password), 10)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// Register cards, card number is taken from user id when card is empty
+func (d *Rac2000Device) SetUsers(users ...User) error {
+	if err := checkUserIds(users); err != nil {
+		return err
+	}
+	for _, user := range users {
+		card, _ := user.Raw.(rac2000.Card)
+		number := user.Card
+		if number == "" {
+			number = user.ID
+		}
+		var err error
+		if card.FacilityCode, card.Id, err = parseCard(number); err != nil {
+			return err
+		}
+		card.Password = 0
+		if user.Password != "" {
+			password, err := strconv.ParseUint(user.Password, 10, 32)
+			if err != nil {
+				return fmt.Errorf("password of RAC2000 must be a number: user %s", user.ID)
+			}
+			card.Password = uint32(password)
+		}
+		if ok, err := d.dev.AddCard(card); !ok || err != nil {
+			return DriverError(ok, err, "set user "+user.ID)
+		}
+	}
+	return nil
+}
+
+func (d *Rac2000Device) DeleteUsers(ids ...string) error {
+	for _, id := range ids {
+		facilityCode, cardId, err := parseCard(id)
+		if err != nil {
+			return err
+		}
+		if ok, err := d.dev.DelCard(facilityCode, cardId); !ok || err != nil {
+			return DriverError(ok, err, "delete user "+id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package absen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Connection parameter used by a driver besides address and timeout
+type Param string
+
+const (
+	ParamMachineID   Param = "machine_id"   // Options.MachineID
+	ParamPassword    Param = "password"     // Options.Password
+	ParamProtocolKey Param = "protocol_key" // Options.ProtocolKey
+)
+
+// Driver of a machine model, registered by the adapter package with Register
+type Driver struct {
+	Model       string // lower case model name used by --model flags and device files
+	Description string
+	DefaultPort int
+	Params      []Param // connection parameters read from Options
+	Open        func(options Options) (Device, error)
+}
+
+// Check whether driver reads the connection parameter
+func (d Driver) Uses(param Param) bool {
+	for _, item := range d.Params {
+		if item == param {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	driversMutex sync.RWMutex
+	drivers      = make(map[string]Driver)
+)
+
+// Make driver available by its model name. Panics when model is empty, already registered or Open is nil.
+func Register(driver Driver) {
+	model := strings.ToLower(driver.Model)
+	if model == "" || driver.Open == nil {
+		panic("absen: driver without model or open function")
+	}
+	driversMutex.Lock()
+	defer driversMutex.Unlock()
+	if _, ok := drivers[model]; ok {
+		panic("absen: driver registered twice for model " + model)
+	}
+	driver.Model = model
+	drivers[model] = driver
+}
+
+// Find driver of model, model name is case insensitive
+func Lookup(model string) (Driver, bool) {
+	driversMutex.RLock()
+	defer driversMutex.RUnlock()
+	driver, ok := drivers[strings.ToLower(model)]
+	return driver, ok
+}
+
+// Registered drivers sorted by model name
+func Drivers() []Driver {
+	driversMutex.RLock()
+	defer driversMutex.RUnlock()
+	list := make([]Driver, 0, len(drivers))
+	for _, driver := range drivers {
+		list = append(list, driver)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Model < list[j].Model })
+	return list
+}
+
+// Registered model names sorted
+func Models() []string {
+	list := Drivers()
+	models := make([]string, 0, len(list))
+	for _, driver := range list {
+		models = append(models, driver.Model)
+	}
+	return models
+}
+
+// Error of unknown model listing registered models
+func UnknownModelError(model string) error {
+	return fmt.Errorf("unknown model %q, use %s", model, strings.Join(Models(), ", "))
+}
+
+// Connect to machine with driver of model
+func Open(model string, options Options) (Device, error) {
+	driver, ok := Lookup(model)
+	if !ok {
+		return nil, UnknownModelError(model)
+	}
+	return driver.Open(options)
+}
@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiDefaultLimit = 100
+	apiMaxLimit     = 1000
+)
+
+// Read only query API over stored data, mounted at /api/
+type apiHandler struct {
+	db    *gorm.DB
+	token string
+	mux   *http.ServeMux
+}
+
+func newAPIHandler(db *gorm.DB, token string) *apiHandler {
+	api := &apiHandler{db: db, token: token, mux: http.NewServeMux()}
+	api.mux.HandleFunc("/api/logs", api.logs)
+	api.mux.HandleFunc("/api/users", api.users)
+	api.mux.HandleFunc("/api/devices", api.devices)
+	api.mux.HandleFunc("/api/devices/", api.device)
+	api.mux.HandleFunc("/api/commands/", api.command)
+	return api
+}
+
+func (api *apiHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	// commands are queued by POST, other endpoints are read only
+	if request.Method != http.MethodGet && !(request.Method == http.MethodPost && strings.HasSuffix(request.URL.Path, "/commands")) {
+		writeAPIError(writer, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", request.Method))
+		return
+	}
+	if api.token == "" && request.Method == http.MethodPost {
+		writeAPIError(writer, http.StatusForbidden, fmt.Errorf("commands can't be queued without api token, start server with --api-token"))
+		return
+	}
+	if api.token != "" {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) != 1 {
+			writeAPIError(writer, http.StatusUnauthorized, fmt.Errorf("invalid api token"))
+			return
+		}
+	}
+	api.mux.ServeHTTP(writer, request)
+}
+
+// Page of query result, next cursor is empty on the last page
+type apiPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+type apiLog struct {
+	ID         uint      `json:"id"`
+	DeviceID   string    `json:"device_id"`
+	UserID     uint      `json:"user_id"`
+	Time       time.Time `json:"time"`
+	VerifyMode string    `json:"verify_mode"`
+	IoMode     uint16    `json:"io_mode"`
+	InOut      string    `json:"in_out"`
+	DoorMode   string    `json:"door_mode"`
+}
+
+// User with summary of enrolled credentials, templates and photos are not included
+type apiUser struct {
+	ID           uint   `json:"id"`
+	Number       string `json:"number"`
+	Name         string `json:"name"`
+	Privilege    uint16 `json:"privilege"`
+	Card         string `json:"card"`
+	Fingerprints int    `json:"fingerprints"`
+	HasFace      bool   `json:"has_face"`
+	HasPhoto     bool   `json:"has_photo"`
+	HasPassword  bool   `json:"has_password"`
+	ValidStart   string `json:"valid_start"`
+	ValidEnd     string `json:"valid_end"`
+}
+
+type apiDevice struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Model      string     `json:"model"`
+	Mode       string     `json:"mode"`
+	Status     string     `json:"status"`
+	IPAddress  string     `json:"ip_address"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+type apiStatusChange struct {
+	ID         uint       `json:"id"`
+	DeviceID   string     `json:"device_id"`
+	Online     bool       `json:"online"`
+	ChangedAt  time.Time  `json:"changed_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+type apiCommand struct {
+	ID          uint       `json:"id"` // trans_id sent to device
+	DeviceID    string     `json:"device_id"`
+	Kind        string     `json:"kind"`
+	Code        string     `json:"code"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	ResultCode  *int32     `json:"result_code"`
+	Result      string     `json:"result"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SentAt      *time.Time `json:"sent_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func newAPICommand(command DeviceCommand) apiCommand {
+	item := apiCommand{ID: command.ID, DeviceID: command.DeviceID, Kind: command.Kind, Code: command.Code, Status: command.Status,
+		Attempts: command.Attempts, Result: command.Result, CreatedAt: command.CreatedAt}
+	if command.ResultCode.Valid {
+		item.ResultCode = &command.ResultCode.Int32
+	}
+	if command.SentAt.Valid {
+		item.SentAt = &command.SentAt.Time
+	}
+	if command.CompletedAt.Valid {
+		item.CompletedAt = &command.CompletedAt.Time
+	}
+	return item
+}
+
+// Logs filtered by user_id, device_id, since and until, ordered by id
+func (api *apiHandler) logs(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	cursor, limit, err := pageParams(request)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err)
+		return
+	}
+	tx := api.db.WithContext(request.Context()).Model(&Log{}).Where("id > ?", cursor)
+	if value := query.Get("user_id"); value != "" {
+		userId, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			writeAPIError(writer, http.StatusBadRequest, fmt.Errorf("invalid user_id %q", value))
+			return
+		}
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if value := query.Get("device_id"); value != "" {
+		tx = tx.Where("device_id = ?", value)
+	}
+	if value := query.Get("since"); value != "" {
+		since, err := parseAPITime(value)
+		if err != nil {
+			writeAPIError(writer, http.StatusBadRequest, err)
+			return
+		}
+		tx = tx.Where("time >= ?", since)
+	}
+	if value := query.Get("until"); value != "" {
+		until, err := parseAPITime(value)
+		if err != nil {
+			writeAPIError(writer, http.StatusBadRequest, err)
+			return
+		}
+		tx = tx.Where("time < ?", until)
+	}
+	var records []Log
+	if err := tx.Order("id").Limit(limit).Find(&records).Error; err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]apiLog, len(records))
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		items[i] = apiLog{ID: record.ID, DeviceID: record.DeviceID, UserID: record.UserID, Time: record.Time, VerifyMode: record.VerifyMode, IoMode: record.IoMode, InOut: record.InOut, DoorMode: record.DoorMode}
+		rows[i] = []string{strconv.Itoa(int(record.ID)), record.DeviceID, strconv.Itoa(int(record.UserID)), record.Time.Format(time.RFC3339), record.VerifyMode, strconv.Itoa(int(record.IoMode)), record.InOut, record.DoorMode}
+	}
+	next := ""
+	if len(records) == limit {
+		next = strconv.Itoa(int(records[len(records)-1].ID))
+	}
+	writePage(writer, request, apiPage{Items: items, NextCursor: next},
+		[]string{"id", "device_id", "user_id", "time", "verify_mode", "io_mode", "in_out", "door_mode"}, rows)
+}
+
+// Users with enrolment summary, ordered by id
+func (api *apiHandler) users(writer http.ResponseWriter, request *http.Request) {
+	cursor, limit, err := pageParams(request)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err)
+		return
+	}
+	type userRow struct {
+		ID           uint
+		Number       string
+		Name         string
+		Privilage    uint16
+		Card         string
+		Fingerprints string
+		HasFace      bool
+		HasPhoto     bool
+		HasPassword  bool
+		ValidStart   string
+		ValidEnd     string
+	}
+	var records []userRow
+	err = api.db.WithContext(request.Context()).Model(&User{}).
+		Select("id, number, name, privilage, card, fingerprints, valid_start, valid_end, "+
+			"CASE WHEN face IS NULL OR face = '' THEN 0 ELSE 1 END AS has_face, "+
+			"CASE WHEN photo IS NULL OR photo = '' THEN 0 ELSE 1 END AS has_photo, "+
+			"CASE WHEN password IS NULL OR password = '' THEN 0 ELSE 1 END AS has_password").
+		Where("id > ?", cursor).Order("id").Limit(limit).
+		Scan(&records).Error
+	if err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]apiUser, len(records))
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		var fingerprints []string
+		json.Unmarshal([]byte(record.Fingerprints), &fingerprints)
+		count := 0
+		for _, fingerprint := range fingerprints {
+			if fingerprint != "" {
+				count++
+			}
+		}
+		items[i] = apiUser{ID: record.ID, Number: record.Number, Name: record.Name, Privilege: record.Privilage, Card: record.Card, Fingerprints: count,
+			HasFace: record.HasFace, HasPhoto: record.HasPhoto, HasPassword: record.HasPassword, ValidStart: record.ValidStart, ValidEnd: record.ValidEnd}
+		rows[i] = []string{strconv.Itoa(int(record.ID)), record.Number, record.Name, strconv.Itoa(int(record.Privilage)), record.Card, strconv.Itoa(count),
+			strconv.FormatBool(record.HasFace), strconv.FormatBool(record.HasPhoto), strconv.FormatBool(record.HasPassword), record.ValidStart, record.ValidEnd}
+	}
+	next := ""
+	if len(records) == limit {
+		next = strconv.Itoa(int(records[len(records)-1].ID))
+	}
+	writePage(writer, request, apiPage{Items: items, NextCursor: next},
+		[]string{"id", "number", "name", "privilege", "card", "fingerprints", "has_face", "has_photo", "has_password", "valid_start", "valid_end"}, rows)
+}
+
+// Devices with online status and last seen time, all devices are returned in one page
+func (api *apiHandler) devices(writer http.ResponseWriter, request *http.Request) {
+	var devices []Device
+	if err := api.db.WithContext(request.Context()).Order("id").Find(&devices).Error; err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]apiDevice, len(devices))
+	rows := make([][]string, len(devices))
+	for i, device := range devices {
+		items[i] = apiDevice{ID: device.ID, Name: device.Name, Model: device.Model, Mode: device.Mode, Status: device.Status, IPAddress: device.IPAddress, Online: device.IsOnline}
+		lastSeen := ""
+		if device.LastSeenAt.Valid {
+			lastSeenAt := device.LastSeenAt.Time
+			items[i].LastSeenAt = &lastSeenAt
+			lastSeen = lastSeenAt.Format(time.RFC3339)
+		}
+		rows[i] = []string{device.ID, device.Name, device.Model, device.Mode, device.Status, device.IPAddress, strconv.FormatBool(items[i].Online), lastSeen}
+	}
+	writePage(writer, request, apiPage{Items: items},
+		[]string{"id", "name", "model", "mode", "status", "ip_address", "online", "last_seen_at"}, rows)
+}
+
+// Resources of registered device at /api/devices/{id}/commands and /api/devices/{id}/history
+func (api *apiHandler) device(writer http.ResponseWriter, request *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, "/api/devices/"), "/")
+	if len(parts) != 2 || (parts[1] != "commands" && parts[1] != "history") {
+		http.NotFound(writer, request)
+		return
+	}
+	deviceId := parts[0]
+	var count int64
+	if err := api.db.WithContext(request.Context()).Model(&Device{}).Where("id = ?", deviceId).Count(&count).Error; err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	if count == 0 {
+		writeAPIError(writer, http.StatusNotFound, fmt.Errorf("device %s is not registered", deviceId))
+		return
+	}
+	if parts[1] == "history" {
+		api.deviceHistory(writer, request, deviceId)
+	} else {
+		api.deviceCommands(writer, request, deviceId)
+	}
+}
+
+// Queue command by POST or list commands of device filtered by status
+func (api *apiHandler) deviceCommands(writer http.ResponseWriter, request *http.Request, deviceId string) {
+	db := api.db.WithContext(request.Context())
+	if request.Method == http.MethodPost {
+		var body struct {
+			Kind string          `json:"kind"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestSize)).Decode(&body); err != nil {
+			writeAPIError(writer, http.StatusBadRequest, fmt.Errorf("invalid command: %v", err))
+			return
+		}
+		command, err := enqueueCommand(db, deviceId, body.Kind, body.Data)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				status = reqErr.status
+			}
+			writeAPIError(writer, status, err)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusCreated)
+		json.NewEncoder(writer).Encode(newAPICommand(command))
+		return
+	}
+	cursor, limit, err := pageParams(request)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err)
+		return
+	}
+	tx := db.Where("device_id = ? AND id > ?", deviceId, cursor)
+	if status := request.URL.Query().Get("status"); status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	var commands []DeviceCommand
+	if err := tx.Order("id").Limit(limit).Find(&commands).Error; err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]apiCommand, len(commands))
+	rows := make([][]string, len(commands))
+	for i, command := range commands {
+		items[i] = newAPICommand(command)
+		resultCode := ""
+		if command.ResultCode.Valid {
+			resultCode = strconv.Itoa(int(command.ResultCode.Int32))
+		}
+		rows[i] = []string{strconv.Itoa(int(command.ID)), command.DeviceID, command.Kind, command.Code, command.Status, strconv.Itoa(command.Attempts), resultCode, command.CreatedAt.Format(time.RFC3339)}
+	}
+	next := ""
+	if len(commands) == limit {
+		next = strconv.Itoa(int(commands[len(commands)-1].ID))
+	}
+	writePage(writer, request, apiPage{Items: items, NextCursor: next},
+		[]string{"id", "device_id", "kind", "code", "status", "attempts", "result_code", "created_at"}, rows)
+}
+
+// Online and offline transitions of device filtered by since and until, ordered by id
+func (api *apiHandler) deviceHistory(writer http.ResponseWriter, request *http.Request, deviceId string) {
+	query := request.URL.Query()
+	cursor, limit, err := pageParams(request)
+	if err != nil {
+		writeAPIError(writer, http.StatusBadRequest, err)
+		return
+	}
+	tx := api.db.WithContext(request.Context()).Where("device_id = ? AND id > ?", deviceId, cursor)
+	if value := query.Get("since"); value != "" {
+		since, err := parseAPITime(value)
+		if err != nil {
+			writeAPIError(writer, http.StatusBadRequest, err)
+			return
+		}
+		tx = tx.Where("changed_at >= ?", since)
+	}
+	if value := query.Get("until"); value != "" {
+		until, err := parseAPITime(value)
+		if err != nil {
+			writeAPIError(writer, http.StatusBadRequest, err)
+			return
+		}
+		tx = tx.Where("changed_at < ?", until)
+	}
+	var changes []DeviceStatusChange
+	if err := tx.Order("id").Limit(limit).Find(&changes).Error; err != nil {
+		writeAPIError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]apiStatusChange, len(changes))
+	rows := make([][]string, len(changes))
+	for i, change := range changes {
+		items[i] = apiStatusChange{ID: change.ID, DeviceID: change.DeviceID, Online: change.Online, ChangedAt: change.ChangedAt}
+		lastSeen := ""
+		if change.LastSeenAt.Valid {
+			lastSeenAt := change.LastSeenAt.Time
+			items[i].LastSeenAt = &lastSeenAt
+			lastSeen = lastSeenAt.Format(time.RFC3339)
+		}
+		rows[i] = []string{strconv.Itoa(int(change.ID)), change.DeviceID, strconv.FormatBool(change.Online), change.ChangedAt.Format(time.RFC3339), lastSeen}
+	}
+	next := ""
+	if len(changes) == limit {
+		next = strconv.Itoa(int(changes[len(changes)-1].ID))
+	}
+	writePage(writer, request, apiPage{Items: items, NextCursor: next},
+		[]string{"id", "device_id", "online", "changed_at", "last_seen_at"}, rows)
+}
+
+// Command status and result at /api/commands/{id}
+func (api *apiHandler) command(writer http.ResponseWriter, request *http.Request) {
+	value := strings.TrimPrefix(request.URL.Path, "/api/commands/")
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		http.NotFound(writer, request)
+		return
+	}
+	var command DeviceCommand
+	result := api.db.WithContext(request.Context()).Limit(1).Find(&command, id)
+	if result.Error != nil {
+		writeAPIError(writer, http.StatusInternalServerError, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeAPIError(writer, http.StatusNotFound, fmt.Errorf("command %d is not found", id))
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(newAPICommand(command))
+}
+
+// Read cursor and limit query parameters
+func pageParams(request *http.Request) (uint64, int, error) {
+	query := request.URL.Query()
+	var cursor uint64
+	if value := query.Get("cursor"); value != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid cursor %q", value)
+		}
+	}
+	limit := apiDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > apiMaxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", apiMaxLimit)
+		}
+	}
+	return cursor, limit, nil
+}
+
+// Parse time in RFC 3339 or date only in local time zone
+func parseAPITime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or yyyy-mm-dd", value)
+}
+
+// CSV is returned when requested by format parameter or Accept header, otherwise JSON
+func wantsCSV(request *http.Request) bool {
+	if format := request.URL.Query().Get("format"); format != "" {
+		return format == "csv"
+	}
+	return strings.Contains(request.Header.Get("Accept"), "text/csv")
+}
+
+func writePage(writer http.ResponseWriter, request *http.Request, page apiPage, header []string, rows [][]string) {
+	if wantsCSV(request) {
+		if page.NextCursor != "" {
+			writer.Header().Set("X-Next-Cursor", page.NextCursor)
+		}
+		writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Write(header)
+		csvWriter.WriteAll(rows)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(&page)
+}
+
+func writeAPIError(writer http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		logEvent("error", "api request failed", "status", status, "error", err)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(map[string]string{"error": err.Error()})
+}
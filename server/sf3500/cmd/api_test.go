@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		cursor  uint64
+		limit   int
+		wantErr bool
+	}{
+		{"", 0, apiDefaultLimit, false},
+		{"cursor=42&limit=10", 42, 10, false},
+		{"cursor=18446744073709551615", 18446744073709551615, apiDefaultLimit, false},
+		{"cursor=-1", 0, 0, true},
+		{"cursor=abc", 0, 0, true},
+		{"limit=0", 0, 0, true},
+		{"limit=1001", 0, 0, true},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/logs?"+test.query, nil)
+		cursor, limit, err := pageParams(request)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error = %v, want error %v", test.query, err, test.wantErr)
+			continue
+		}
+		if cursor != test.cursor || limit != test.limit {
+			t.Errorf("%q: cursor, limit = %d, %d, want %d, %d", test.query, cursor, limit, test.cursor, test.limit)
+		}
+	}
+}
+
+func TestAPILogsPaging(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.Create(&Device{ID: "1", Status: deviceApproved}).Error; err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := db.Create(&Log{DeviceID: "1", UserID: uint(i + 1), Time: begin.Add(time.Duration(i) * time.Minute), InOut: "in"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := newAPIHandler(db, "")
+
+	// cursor of each page is the id of its last item, the last page has no next cursor
+	var ids []uint
+	var cursors []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		query := url.Values{"limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/logs?"+query.Encode(), nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+		}
+		var result struct {
+			Items      []apiLog `json:"items"`
+			NextCursor string   `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range result.Items {
+			ids = append(ids, item.UserID)
+		}
+		cursors = append(cursors, result.NextCursor)
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	if !reflect.DeepEqual(ids, []uint{1, 2, 3, 4, 5}) {
+		t.Errorf("users of logs = %v, want [1 2 3 4 5]", ids)
+	}
+	if !reflect.DeepEqual(cursors, []string{"2", "4", ""}) {
+		t.Errorf("cursors = %q, want [2 4 \"\"]", cursors)
+	}
+
+	// csv page has the next cursor in header
+	recorder := httptest.NewRecorder()
+	api.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/logs?format=csv&limit=2&cursor=2", nil))
+	if next := recorder.Header().Get("X-Next-Cursor"); next != "4" {
+		t.Errorf("X-Next-Cursor = %q, want 4", next)
+	}
+
+	recorder = httptest.NewRecorder()
+	api.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/logs?cursor=x", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("status of invalid cursor = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAPICommandPostRequiresToken(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.Create(&Device{ID: "1", Status: deviceApproved}).Error; err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		token  string // token of server
+		auth   string // token sent by client
+		method string
+		status int
+	}{
+		{"post without server token", "", "", http.MethodPost, http.StatusForbidden},
+		{"post with client token only", "", "s3cret", http.MethodPost, http.StatusForbidden},
+		{"get without server token", "", "", http.MethodGet, http.StatusOK},
+		{"post without client token", "s3cret", "", http.MethodPost, http.StatusUnauthorized},
+		{"post with invalid token", "s3cret", "guess", http.MethodPost, http.StatusUnauthorized},
+		{"post with token", "s3cret", "s3cret", http.MethodPost, http.StatusCreated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := newAPIHandler(db, test.token)
+			request := httptest.NewRequest(test.method, "/api/devices/1/commands", strings.NewReader(`{"kind":"open_door"}`))
+			if test.auth != "" {
+				request.Header.Set("Authorization", "Bearer "+test.auth)
+			}
+			recorder := httptest.NewRecorder()
+			api.ServeHTTP(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, test.status, recorder.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device status
+const (
+	deviceApproved = "approved"
+	devicePending  = "pending"
+	deviceBlocked  = "blocked"
+)
+
+// How pushes from devices not registered in database are handled
+const (
+	authOpen       = "open"       // register unknown device and accept its data
+	authAllowlist  = "allowlist"  // reject unknown device
+	authQuarantine = "quarantine" // register unknown device as pending and reject its data until approved
+)
+
+// Time a device is cached, changes made by device command take effect after it
+const deviceCacheTTL = 30 * time.Second
+
+type cachedDevice struct {
+	device   Device
+	found    bool
+	loadedAt time.Time
+}
+
+// Authorize pushes by device status, shared secret and bound IP address
+type deviceAuth struct {
+	db    *gorm.DB
+	mode  string
+	mutex sync.Mutex
+	cache map[string]cachedDevice
+}
+
+func newDeviceAuth(db *gorm.DB, mode string) (*deviceAuth, error) {
+	switch mode {
+	case authOpen, authAllowlist, authQuarantine:
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q, use %s, %s or %s", mode, authOpen, authAllowlist, authQuarantine)
+	}
+	return &deviceAuth{db: db, mode: mode, cache: make(map[string]cachedDevice)}, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Shared secret sent by device in dev_secret header or as password of basic authentication
+func requestSecret(request *http.Request) string {
+	if secret := request.Header.Get("dev_secret"); secret != "" {
+		return secret
+	}
+	if _, password, ok := request.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+func forbidden(format string, a ...interface{}) error {
+	return &requestError{status: http.StatusForbidden, err: fmt.Errorf(format, a...)}
+}
+
+// Check whether device is allowed to push data
+func (auth *deviceAuth) authorize(ctx context.Context, request *http.Request, deviceId string, remoteHost string) error {
+	device, found, err := auth.lookup(ctx, deviceId)
+	if err != nil {
+		return err
+	}
+	if !found {
+		switch auth.mode {
+		case authAllowlist:
+			return forbidden("device %s is not allowed", deviceId)
+		case authQuarantine:
+			device = Device{ID: deviceId, Model: request.Header.Get("dev_model"), IPAddress: remoteHost, Mode: "push", Status: devicePending}
+			if err := auth.db.WithContext(ctx).Create(&device).Error; err != nil {
+				return err
+			}
+			auth.forget(deviceId)
+			logEvent("warning", "unknown device quarantined, approve it with device allow command", "dev_id", deviceId, "remote", remoteHost)
+			return forbidden("device %s is waiting for approval", deviceId)
+		}
+		return nil
+	}
+	switch device.Status {
+	case devicePending:
+		return forbidden("device %s is waiting for approval", deviceId)
+	case deviceBlocked:
+		return forbidden("device %s is blocked", deviceId)
+	}
+	if device.BindIP != "" && device.BindIP != remoteHost {
+		return forbidden("device %s is not allowed from %s", deviceId, remoteHost)
+	}
+	if device.SecretHash != "" {
+		secret := requestSecret(request)
+		if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(device.SecretHash)) != 1 {
+			return &requestError{status: http.StatusUnauthorized, err: fmt.Errorf("invalid secret of device %s", deviceId)}
+		}
+	}
+	return nil
+}
+
+// Find device in cache or database, cached device is used while database is unreachable
+func (auth *deviceAuth) lookup(ctx context.Context, deviceId string) (Device, bool, error) {
+	auth.mutex.Lock()
+	cached, ok := auth.cache[deviceId]
+	auth.mutex.Unlock()
+	if ok && time.Since(cached.loadedAt) < deviceCacheTTL {
+		return cached.device, cached.found, nil
+	}
+	var device Device
+	result := auth.db.WithContext(ctx).Limit(1).Find(&device, "id = ?", deviceId)
+	if result.Error != nil {
+		if ok {
+			return cached.device, cached.found, nil
+		}
+		return device, false, result.Error
+	}
+	cached = cachedDevice{device: device, found: result.RowsAffected > 0, loadedAt: time.Now()}
+	auth.mutex.Lock()
+	auth.cache[deviceId] = cached
+	auth.mutex.Unlock()
+	return cached.device, cached.found, nil
+}
+
+func (auth *deviceAuth) forget(deviceId string) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	delete(auth.cache, deviceId)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Open migrated sqlite database in temporary directory
+func newTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := openDatabase("file:" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestDeviceAuthorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		device  *Device // registered device, nil for unknown device
+		remote  string
+		secret  string
+		status  int    // status of rejected push, 0 if accepted
+		created string // status of device registered by the push, empty if none
+	}{
+		{name: "unknown device in open mode", mode: authOpen, remote: "10.0.0.1"},
+		{name: "unknown device in allowlist mode", mode: authAllowlist, remote: "10.0.0.1", status: http.StatusForbidden},
+		{name: "unknown device in quarantine mode", mode: authQuarantine, remote: "10.0.0.1", status: http.StatusForbidden, created: devicePending},
+		{name: "approved device", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved}, remote: "10.0.0.1"},
+		{name: "pending device", mode: authOpen, device: &Device{ID: "1", Status: devicePending}, remote: "10.0.0.1", status: http.StatusForbidden},
+		{name: "blocked device", mode: authOpen, device: &Device{ID: "1", Status: deviceBlocked}, remote: "10.0.0.1", status: http.StatusForbidden},
+		{name: "bound address", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved, BindIP: "10.0.0.1"}, remote: "10.0.0.1"},
+		{name: "other address", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved, BindIP: "10.0.0.1"}, remote: "10.0.0.2", status: http.StatusForbidden},
+		{name: "valid secret", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved, SecretHash: hashSecret("s3cret")}, remote: "10.0.0.1", secret: "s3cret"},
+		{name: "invalid secret", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved, SecretHash: hashSecret("s3cret")}, remote: "10.0.0.1", secret: "guess", status: http.StatusUnauthorized},
+		{name: "missing secret", mode: authAllowlist, device: &Device{ID: "1", Status: deviceApproved, SecretHash: hashSecret("s3cret")}, remote: "10.0.0.1", status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			if test.device != nil {
+				if err := db.Create(test.device).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			auth, err := newDeviceAuth(db, test.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			if test.secret != "" {
+				request.Header.Set("dev_secret", test.secret)
+			}
+			err = auth.authorize(context.Background(), request, "1", test.remote)
+			status := 0
+			if err != nil {
+				var reqErr *requestError
+				if !errors.As(err, &reqErr) {
+					t.Fatalf("error = %v, want request error", err)
+				}
+				status = reqErr.status
+			}
+			if status != test.status {
+				t.Errorf("status = %d, want %d, error %v", status, test.status, err)
+			}
+			if test.device == nil {
+				var device Device
+				found := db.Limit(1).Find(&device, "id = ?", "1").RowsAffected > 0
+				if found != (test.created != "") || device.Status != test.created {
+					t.Errorf("registered device found %v with status %q, want %q", found, device.Status, test.created)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDeviceAuthRejectsUnknownMode(t *testing.T) {
+	if _, err := newDeviceAuth(nil, "trust"); err == nil {
+		t.Fatal("expected error of unknown auth mode")
+	}
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/rac2000"
+	"github.com/masykur/absen/pkg/sf3000"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var collectorCommand = &cobra.Command{
+	Use:   "collector",
+	Short: "Pull log data from devices in pull mode",
+	Long: "Collector connects to devices in pull mode periodically and stores their logs in the same database as pushed data. " +
+		"Devices are registered in database with pull mode, devices in the devices file are registered when the collector is started.",
+	Example: "sf3500 collector --dsn file:faceid.db --devices-file devices.yaml --interval 5m --concurrency 4",
+	Args:    cobra.ExactArgs(0),
+	Run:     runCollector}
+
+var (
+	devicesFile    string
+	collectEvery   time.Duration
+	collectWorkers int
+	connectTimeout time.Duration
+	collectTimeout time.Duration
+)
+
+const (
+	// First retry delay after collecting from a device failed, doubled on each failure
+	collectRetryDelay = 30 * time.Second
+	// Interval of checking which devices are due
+	collectTick = 5 * time.Second
+)
+
+func init() {
+	collectorCommand.Flags().StringVar(&devicesFile, "devices-file", "", "YAML or JSON file listing devices to register in pull mode")
+	collectorCommand.Flags().DurationVar(&collectEvery, "interval", 5*time.Minute, "Time between collections of a device")
+	collectorCommand.Flags().IntVar(&collectWorkers, "concurrency", 4, "Maximum number of devices collected at the same time")
+	collectorCommand.Flags().DurationVar(&connectTimeout, "connect-timeout", 20*time.Second, "Timeout of connecting and each command sent to device")
+	collectorCommand.Flags().DurationVar(&collectTimeout, "device-timeout", 5*time.Minute, "Connection is closed when collecting from a device takes longer")
+	RootCmd.AddCommand(collectorCommand)
+}
+
+// Device listed in devices file
+type pullDeviceConfig struct {
+	ID        string `mapstructure:"id"`
+	Name      string `mapstructure:"name"`
+	Model     string `mapstructure:"model"` // model of registered driver, sf3000, sf3500 or rac2000
+	Address   string `mapstructure:"address"`
+	Port      uint16 `mapstructure:"port"`
+	MachineID uint16 `mapstructure:"machine_id"`
+	Password  string `mapstructure:"password"`
+}
+
+// Register or update devices listed in devices file
+func registerPullDevices(db *gorm.DB, file string) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	var configs []pullDeviceConfig
+	if err := v.UnmarshalKey("devices", &configs); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if config.ID == "" || config.Address == "" {
+			return fmt.Errorf("id and address of device are required")
+		}
+		driver, ok := absen.Lookup(config.Model)
+		if !ok {
+			return fmt.Errorf("device %s: %v", config.ID, absen.UnknownModelError(config.Model))
+		}
+		model := driver.Model
+		if config.Port == 0 {
+			config.Port = uint16(driver.DefaultPort)
+		}
+		values := Device{ID: config.ID, Name: config.Name, Model: model, Mode: "pull", IPAddress: config.Address, Port: config.Port,
+			MachineID: config.MachineID, Password: config.Password, Status: deviceApproved}
+		var count int64
+		if err := db.Unscoped().Model(&Device{}).Where("id = ?", config.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := db.Create(&values).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		// blocked device is kept blocked
+		if err := db.Unscoped().Model(&Device{}).Where("id = ?", config.ID).Updates(map[string]interface{}{
+			"name": config.Name, "model": model, "mode": "pull", "ip_address": config.Address, "port": config.Port,
+			"machine_id": config.MachineID, "password": config.Password, "deleted_at": nil}).Error; err != nil {
+			return err
+		}
+	}
+	logEvent("info", "devices registered", "file", file, "count", len(configs))
+	return nil
+}
+
+// Schedule state of a device
+type collectState struct {
+	running  bool
+	nextAt   time.Time
+	failures int
+}
+
+// Collect logs from pull mode devices with bounded concurrency
+type collector struct {
+	db      *gorm.DB
+	workers chan struct{}
+	mutex   sync.Mutex
+	states  map[string]*collectState
+	wg      sync.WaitGroup
+}
+
+func runCollector(cmd *cobra.Command, args []string) {
+	db, err := openDatabase(dsn)
+	if err != nil {
+		log.Fatalln("failed to connect database:", err)
+	}
+	if err := migrateDatabase(db); err != nil {
+		log.Fatalln("failed to migrate database:", err)
+	}
+	if devicesFile != "" {
+		if err := registerPullDevices(db, devicesFile); err != nil {
+			log.Fatalln("failed to register devices:", err)
+		}
+	}
+	if collectWorkers < 1 {
+		collectWorkers = 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logEvent("info", "collector started", "interval", collectEvery, "concurrency", collectWorkers)
+	c := &collector{db: db, workers: make(chan struct{}, collectWorkers), states: make(map[string]*collectState)}
+	c.run(ctx)
+	logEvent("info", "collector stopped")
+}
+
+// Start collecting from due devices until context is done, then wait for running collections
+func (c *collector) run(ctx context.Context) {
+	ticker := time.NewTicker(collectTick)
+	defer ticker.Stop()
+	for {
+		var devices []Device
+		if err := c.db.WithContext(ctx).Where("mode = ? AND status = ?", "pull", deviceApproved).Order("id").Find(&devices).Error; err != nil {
+			if ctx.Err() == nil {
+				logEvent("error", "read devices failed", "error", err)
+			}
+		}
+		now := time.Now()
+		for _, device := range devices {
+			c.mutex.Lock()
+			state, ok := c.states[device.ID]
+			if !ok {
+				state = &collectState{}
+				c.states[device.ID] = state
+			}
+			due := !state.running && !now.Before(state.nextAt)
+			if due {
+				state.running = true
+			}
+			c.mutex.Unlock()
+			if due {
+				c.wg.Add(1)
+				go c.collect(ctx, device, state)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			c.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect logs from device and schedule the next collection, failed device is retried with backoff
+func (c *collector) collect(ctx context.Context, device Device, state *collectState) {
+	defer c.wg.Done()
+	select {
+	case c.workers <- struct{}{}:
+	case <-ctx.Done():
+		c.mutex.Lock()
+		state.running = false
+		c.mutex.Unlock()
+		return
+	}
+	begin := time.Now()
+	count, err := collectDevice(c.db, device)
+	<-c.workers
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	state.running = false
+	if err != nil {
+		state.failures++
+		delay := collectRetryDelay << uint(state.failures-1)
+		if delay > collectEvery || delay <= 0 {
+			delay = collectEvery
+		}
+		state.nextAt = time.Now().Add(delay)
+		logEvent("error", "collect logs failed", "dev_id", device.ID, "model", device.Model, "address", device.IPAddress, "failures", state.failures, "retry_in", delay, "error", err)
+		return
+	}
+	state.failures = 0
+	state.nextAt = begin.Add(collectEvery)
+	logEvent("info", "logs collected", "dev_id", device.ID, "model", device.Model, "count", count, "duration", time.Since(begin))
+}
+
+// Connect to device, store its logs and update its online status. Returns number of fetched logs.
+func collectDevice(db *gorm.DB, device Device) (int, error) {
+	count, err := fetchDeviceLogs(db, device)
+	if err != nil {
+		var connErr *net.OpError
+		if errors.As(err, &connErr) && connErr.Op == "dial" {
+			if statusErr := setDeviceOnline(db, device.ID, false, device.LastSeenAt); statusErr != nil {
+				logEvent("error", "update device status failed", "dev_id", device.ID, "error", statusErr)
+			}
+		}
+		return count, err
+	}
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	if err := db.Model(&Device{}).Where("id = ?", device.ID).Update("last_seen_at", now).Error; err != nil {
+		return count, err
+	}
+	return count, setDeviceOnline(db, device.ID, true, now)
+}
+
+// Close connection when collecting takes too long, drivers waiting for data return with error
+func watchdog(close func()) *time.Timer {
+	return time.AfterFunc(collectTimeout, close)
+}
+
+// Connection options of registered device for its driver
+func deviceOptions(driver absen.Driver, device Device) (absen.Options, error) {
+	options := absen.Options{Address: device.IPAddress, MachineID: device.MachineID, Timeout: connectTimeout, DeviceID: device.ID}
+	if device.Port != 0 {
+		options.Address = net.JoinHostPort(device.IPAddress, strconv.Itoa(int(device.Port)))
+	}
+	if driver.Uses(absen.ParamPassword) && device.Password != "" {
+		password, err := strconv.ParseUint(device.Password, 10, 16)
+		if err != nil {
+			return options, fmt.Errorf("password of %s must be a number", strings.ToUpper(driver.Model))
+		}
+		options.Password = uint16(password)
+	}
+	return options, nil
+}
+
+// Fetch logs with the driver registered for device model and store them.
+// Logs are marked read only after stored when the machine supports it.
+func fetchDeviceLogs(db *gorm.DB, device Device) (int, error) {
+	driver, ok := absen.Lookup(device.Model)
+	if !ok {
+		return 0, absen.UnknownModelError(device.Model)
+	}
+	options, err := deviceOptions(driver, device)
+	if err != nil {
+		return 0, err
+	}
+	dev, err := driver.Open(options)
+	if err != nil {
+		return 0, err
+	}
+	defer dev.Close()
+	defer watchdog(func() { dev.Close() }).Stop()
+	if source, ok := dev.(absen.MarkingLogSource); ok {
+		count, err := source.PunchesAndMark(func(punches []absen.Punch) error {
+			return saveLogs(db, pendingLogs(punches))
+		})
+		var unpersisted *sf3500.UnpersistedLogsError
+		if errors.As(err, &unpersisted) {
+			// keep logs in event log, the machine doesn't return them again
+			data, _ := json.Marshal(unpersisted.Logs)
+			logEvent("error", "logs marked as read are not stored", "dev_id", device.ID, "count", len(unpersisted.Logs), "logs", string(data))
+		}
+		return count, err
+	}
+	source, ok := dev.(absen.LogSource)
+	if !ok {
+		return 0, fmt.Errorf("model %s doesn't keep logs", driver.Model)
+	}
+	punches, err := source.Punches()
+	// logs fetched before an error may be already removed from the machine, store them anyway
+	if saveErr := saveLogs(db, pendingLogs(punches)); saveErr != nil {
+		logEvent("error", "logs fetched from machine are lost", "dev_id", device.ID, "count", len(punches), "error", saveErr)
+		return len(punches), saveErr
+	}
+	return len(punches), err
+}
+
+// Convert punches to logs, machine specific fields are stored the same way as before the common interface
+func pendingLogs(punches []absen.Punch) []pendingLog {
+	pending := make([]pendingLog, 0, len(punches))
+	for _, punch := range punches {
+		if punch.SubjectID == "" {
+			// exit button of RAC2000 has no user
+			continue
+		}
+		userId, err := strconv.Atoi(punch.SubjectID)
+		if err != nil || userId < 0 {
+			logEvent("warning", "log with invalid user id skipped", "dev_id", punch.DeviceID, "user_id", punch.SubjectID)
+			continue
+		}
+		record := Log{DeviceID: punch.DeviceID, UserID: uint(userId), Time: punch.Timestamp, VerifyMode: string(punch.Credential)}
+		var photo string
+		switch raw := punch.Raw.(type) {
+		case models.LogData:
+			record.VerifyMode, record.IoMode, record.InOut, record.DoorMode = raw.VerifyMode, uint16(raw.IoMode), raw.InOut, raw.DoorMode
+			photo = raw.LogPhoto
+		case sf3000.Log:
+			record.VerifyMode, record.IoMode = raw.SensorType.String(), uint16(raw.FunctionNumber)
+			if raw.FunctionKey != sf3000.NoKey {
+				record.InOut = raw.FunctionKey.String()
+			}
+		case rac2000.Log:
+			record.IoMode, record.DoorMode = uint16(raw.Event), fmt.Sprintf("0x%02x", raw.Sensor)
+		default:
+			if punch.Direction != absen.DirectionUnknown {
+				record.InOut = string(punch.Direction)
+			}
+		}
+		pending = append(pending, pendingLog{record: record, photo: photo})
+	}
+	return pending
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var commandCommand = &cobra.Command{
+	Use:   "command",
+	Short: "Queue commands for push devices",
+	Long:  "Commands are delivered in the response of the next request pushed by the device, the device reports the result afterwards"}
+
+var commandAddCommand = &cobra.Command{
+	Use:   "add [device id] [kind]",
+	Short: "Queue command for device",
+	Long:  "Queue command for device, kind is set_user, delete_user, set_time, open_door or clear_logs",
+	Example: "sf3500 command add 1234567890 open_door\n" +
+		"sf3500 command add 1234567890 set_time\n" +
+		"sf3500 command add 1234567890 delete_user --data '{\"usersId\":[\"1001\",\"1002\"]}'\n" +
+		"sf3500 command add 1234567890 set_user --file users.json",
+	Args: cobra.ExactArgs(2),
+	Run:  addCommand}
+
+var commandListCommand = &cobra.Command{
+	Use:   "list [device id]",
+	Short: "List commands of device",
+	Args:  cobra.ExactArgs(1),
+	Run:   listCommands}
+
+var commandCancelCommand = &cobra.Command{
+	Use:   "cancel [command id]...",
+	Short: "Cancel commands not delivered yet",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   cancelCommands}
+
+var (
+	commandDataJson string
+	commandFile     string
+	commandStatus   string
+)
+
+func init() {
+	commandAddCommand.Flags().StringVar(&commandDataJson, "data", "", "Command data in JSON")
+	commandAddCommand.Flags().StringVar(&commandFile, "file", "", "Read command data in JSON from file")
+	commandListCommand.Flags().StringVar(&commandStatus, "status", "", "Show only commands with this status, queued, sent, done, failed or canceled")
+	commandCommand.AddCommand(commandAddCommand)
+	commandCommand.AddCommand(commandListCommand)
+	commandCommand.AddCommand(commandCancelCommand)
+	RootCmd.AddCommand(commandCommand)
+}
+
+func addCommand(cmd *cobra.Command, args []string) {
+	data := []byte(commandDataJson)
+	if commandFile != "" {
+		var err error
+		if data, err = os.ReadFile(commandFile); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	db := openMigratedDatabase()
+	var count int64
+	if err := db.Model(&Device{}).Where("id = ?", args[0]).Count(&count).Error; err != nil {
+		log.Fatalln(err)
+	}
+	if count == 0 {
+		log.Fatalf("device %s is not registered\n", args[0])
+	}
+	command, err := enqueueCommand(db, args[0], args[1], data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Command %d (%s) is queued for device %s\n", command.ID, command.Code, command.DeviceID)
+}
+
+func listCommands(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	tx := db.Where("device_id = ?", args[0])
+	if commandStatus != "" {
+		tx = tx.Where("status = ?", commandStatus)
+	}
+	var commands []DeviceCommand
+	if err := tx.Order("id").Find(&commands).Error; err != nil {
+		log.Fatalln(err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Kind", "Code", "Status", "Attempts", "Result Code", "Created At", "Completed At"})
+	for _, command := range commands {
+		resultCode := ""
+		if command.ResultCode.Valid {
+			resultCode = strconv.Itoa(int(command.ResultCode.Int32))
+		}
+		completedAt := ""
+		if command.CompletedAt.Valid {
+			completedAt = command.CompletedAt.Time.Local().Format("2006-01-02 15:04:05")
+		}
+		table.Append([]string{strconv.Itoa(int(command.ID)), command.Kind, command.Code, command.Status, strconv.Itoa(command.Attempts), resultCode,
+			command.CreatedAt.Local().Format("2006-01-02 15:04:05"), completedAt})
+	}
+	table.Render()
+}
+
+func cancelCommands(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			log.Fatalf("invalid command id %q\n", arg)
+		}
+		if err := cancelCommand(db, uint(id)); err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("Command %d is canceled\n", id)
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/cmds"
+	"github.com/masykur/absen/pkg/sf3500/models"
+	"gorm.io/gorm"
+)
+
+// Command status
+const (
+	commandQueued   = "queued"
+	commandSent     = "sent"
+	commandDone     = "done"
+	commandFailed   = "failed"
+	commandCanceled = "canceled"
+)
+
+const (
+	// Sent command without result is delivered again after this duration
+	commandResendAfter = 5 * time.Minute
+	// Command is failed when the device does not report its result after this number of deliveries
+	commandMaxAttempts = 3
+)
+
+// Data of queued command, fields used depend on command kind
+type commandData struct {
+	Users   []models.User `json:"users"`   // set_user
+	UsersId []string      `json:"usersId"` // delete_user
+	Time    string        `json:"time"`    // set_time, RFC 3339 time, device is synchronized with server time when empty
+	Status  string        `json:"status"`  // open_door, open, keep_open, keep_close or normal
+}
+
+// Create command of kind with data in JSON, command is validated and its payload is built
+func newDeviceCommand(deviceId string, kind string, data []byte) (DeviceCommand, error) {
+	command := DeviceCommand{DeviceID: deviceId, Kind: kind, Status: commandQueued}
+	var params commandData
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &params); err != nil {
+			return command, badRequest("invalid command data: %v", err)
+		}
+	}
+	var payload interface{}
+	switch kind {
+	case "set_user":
+		if len(params.Users) == 0 {
+			return command, badRequest("users are required")
+		}
+		for _, user := range params.Users {
+			if _, err := strconv.Atoi(user.UserID); err != nil {
+				return command, badRequest("invalid user id %q", user.UserID)
+			}
+		}
+		payload = cmds.SetUserInfo{Command: "SetUserInfo", Data: cmds.SetUserInfoData{Users: params.Users}}
+	case "delete_user":
+		if len(params.UsersId) == 0 {
+			return command, badRequest("usersId is required")
+		}
+		payload = cmds.DeleteUser{Command: "DeleteUser", Data: cmds.DeleteUserData{UsersId: params.UsersId}}
+	case "set_time":
+		command.Code = "SetDeviceTime"
+		if params.Time == "" {
+			// payload is built when the command is delivered
+			return command, nil
+		}
+		t, err := time.Parse(time.RFC3339, params.Time)
+		if err != nil {
+			return command, badRequest("invalid time %q, use RFC 3339", params.Time)
+		}
+		payload = setTimeCommand(t)
+	case "open_door":
+		status := sf3500.DoorOpen
+		if params.Status != "" {
+			status = sf3500.DoorStatus(params.Status)
+		}
+		switch status {
+		case sf3500.DoorOpen, sf3500.DoorKeepOpen, sf3500.DoorKeepClosed, sf3500.DoorNormal:
+		default:
+			return command, badRequest("invalid door status %q", params.Status)
+		}
+		payload = cmds.SetDoorStatus{Command: "SetDoorStatus", Data: cmds.SetDoorStatusData{Status: string(status)}}
+	case "clear_logs":
+		payload = cmds.ClearLog{Command: "ClearLogData"}
+	default:
+		return command, badRequest("unknown command %q, use set_user, delete_user, set_time, open_door or clear_logs", kind)
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return command, err
+	}
+	command.Payload = string(encoded)
+	command.Code = commandCode(encoded)
+	return command, nil
+}
+
+func setTimeCommand(t time.Time) cmds.SetDeviceTime {
+	return cmds.SetDeviceTime{Command: "SetDeviceTime", Data: cmds.SetDeviceTimeData{Time: t.In(time.Local).Format("20060102150405")}}
+}
+
+// Command name of encoded command
+func commandCode(payload []byte) string {
+	var command struct {
+		Command string `json:"cmd"`
+	}
+	json.Unmarshal(payload, &command)
+	return command.Command
+}
+
+// Queue command for device
+func enqueueCommand(db *gorm.DB, deviceId string, kind string, data []byte) (DeviceCommand, error) {
+	command, err := newDeviceCommand(deviceId, kind, data)
+	if err != nil {
+		return command, err
+	}
+	err = db.Create(&command).Error
+	return command, err
+}
+
+// Take the next command to deliver to device and mark it sent.
+// Returns nil if there is no command waiting for the device.
+func nextCommand(db *gorm.DB, deviceId string) (*DeviceCommand, error) {
+	for {
+		now := time.Now()
+		var command DeviceCommand
+		result := db.Where("device_id = ? AND (status = ? OR (status = ? AND sent_at < ?))", deviceId, commandQueued, commandSent, now.Add(-commandResendAfter)).
+			Order("id").Limit(1).Find(&command)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		if command.Status == commandSent && command.Attempts >= commandMaxAttempts {
+			if err := db.Model(&command).Updates(map[string]interface{}{"status": commandFailed, "result": "no result reported by device", "completed_at": now}).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if command.Payload == "" && command.Code == "SetDeviceTime" {
+			encoded, err := json.Marshal(setTimeCommand(now))
+			if err != nil {
+				return nil, err
+			}
+			command.Payload = string(encoded)
+		}
+		// another request of the same device may take the command at the same time
+		update := db.Model(&DeviceCommand{}).
+			Where("id = ? AND status = ? AND attempts = ?", command.ID, command.Status, command.Attempts).
+			Updates(map[string]interface{}{"status": commandSent, "attempts": command.Attempts + 1, "sent_at": now, "payload": command.Payload})
+		if update.Error != nil {
+			return nil, update.Error
+		}
+		if update.RowsAffected == 0 {
+			continue
+		}
+		command.Status = commandSent
+		command.Attempts++
+		command.SentAt = sql.NullTime{Time: now, Valid: true}
+		return &command, nil
+	}
+}
+
+// Store command result reported by device. Result code is taken from cmd_return_code header,
+// or result_code of the body when the header is not sent.
+func completeCommand(db *gorm.DB, deviceId string, transId string, returnCode string, body []byte) error {
+	id, err := strconv.ParseUint(transId, 10, 64)
+	if err != nil {
+		return badRequest("invalid trans_id %q", transId)
+	}
+	var resultCode int
+	switch strings.ToUpper(returnCode) {
+	case "":
+		var response models.Response
+		if err := json.Unmarshal(body, &response); err != nil {
+			return badRequest("invalid command result: %v", err)
+		}
+		resultCode = response.ResultCode
+	case "OK":
+		resultCode = 0
+	default:
+		if resultCode, err = strconv.Atoi(returnCode); err != nil {
+			resultCode = -1
+		}
+	}
+	status := commandDone
+	if resultCode != 0 {
+		status = commandFailed
+	}
+	result := db.Model(&DeviceCommand{}).
+		Where("id = ? AND device_id = ? AND status = ?", id, deviceId, commandSent).
+		Updates(map[string]interface{}{"status": status, "result_code": resultCode, "result": string(body), "completed_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		logEvent("warning", "result of unknown command ignored", "dev_id", deviceId, "trans_id", transId)
+	}
+	return nil
+}
+
+// Cancel command not delivered yet
+func cancelCommand(db *gorm.DB, id uint) error {
+	result := db.Model(&DeviceCommand{}).Where("id = ? AND status = ?", id, commandQueued).
+		Updates(map[string]interface{}{"status": commandCanceled, "completed_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("command %d is not queued", id)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var dedupeCommand = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Remove duplicated logs",
+	Long:  "Collapse logs with the same device, user, time and verify mode into one log, the first stored log and its photo are kept",
+	Args:  cobra.ExactArgs(0),
+	Run:   dedupeLogs}
+
+var dedupeDryRun bool
+
+func init() {
+	dedupeCommand.Flags().BoolVar(&dedupeDryRun, "dry-run", false, "Report duplicated logs without removing them")
+	RootCmd.AddCommand(dedupeCommand)
+}
+
+// Logs sharing the same natural key
+type duplicateLog struct {
+	DeviceID   string
+	UserID     uint
+	Time       time.Time
+	VerifyMode string
+	KeepID     uint
+	Count      int
+}
+
+// Find logs sharing the same device, user, time and verify mode
+func findDuplicateLogs(db *gorm.DB) ([]duplicateLog, error) {
+	duplicates := make([]duplicateLog, 0)
+	err := db.Model(&Log{}).
+		Select("device_id, user_id, time, verify_mode, min(id) as keep_id, count(*) as count").
+		Group("device_id, user_id, time, verify_mode").
+		Having("count(*) > 1").
+		Scan(&duplicates).Error
+	return duplicates, err
+}
+
+// Remove duplicated logs and their photos, keep the first stored log.
+// Returns number of removed logs.
+func removeDuplicateLogs(db *gorm.DB, duplicates []duplicateLog) (int, error) {
+	removed := 0
+	for _, duplicate := range duplicates {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			ids := make([]uint, 0, duplicate.Count-1)
+			if err := tx.Model(&Log{}).
+				Where("device_id = ? AND user_id = ? AND time = ? AND verify_mode = ? AND id <> ?", duplicate.DeviceID, duplicate.UserID, duplicate.Time, duplicate.VerifyMode, duplicate.KeepID).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			if err := tx.Unscoped().Where("log_id IN ?", ids).Delete(&LogPhoto{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&Log{}).Error; err != nil {
+				return err
+			}
+			removed += len(ids)
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func dedupeLogs(cmd *cobra.Command, args []string) {
+	db, err := openDatabase(dsn)
+	if err != nil {
+		log.Fatalln("failed to connect database:", err)
+	}
+	duplicates, err := findDuplicateLogs(db)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	total := 0
+	for _, duplicate := range duplicates {
+		total += duplicate.Count - 1
+	}
+	if dedupeDryRun {
+		fmt.Printf("Found %d duplicated logs in %d groups\n", total, len(duplicates))
+		return
+	}
+	removed, err := removeDuplicateLogs(db, duplicates)
+	fmt.Printf("Removed %d of %d duplicated logs\n", removed, total)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// unique index can be created now duplicated logs are removed
+	if err := migrateDatabase(db); err != nil {
+		log.Fatalln("failed to migrate database:", err)
+	}
+}
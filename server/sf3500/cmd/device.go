@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var deviceCommand = &cobra.Command{
+	Use:   "device",
+	Short: "Manage devices allowed to push data",
+	Long:  "Approve, block and list devices pushing data to the server. Running server applies the changes within 30 seconds."}
+
+var deviceListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List registered devices",
+	Args:  cobra.ExactArgs(0),
+	Run:   listDevices}
+
+var deviceAllowCommand = &cobra.Command{
+	Use:     "allow [device id]...",
+	Short:   "Allow or approve devices",
+	Long:    "Register devices as approved or approve quarantined devices, optionally require a shared secret or bind them to an IP address",
+	Example: "sf3500 device allow 1234567890 --secret s3cr3t --bind-ip 192.168.0.10",
+	Args:    cobra.MinimumNArgs(1),
+	Run:     allowDevices}
+
+var deviceBlockCommand = &cobra.Command{
+	Use:   "block [device id]...",
+	Short: "Reject data pushed by devices",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   blockDevices}
+
+var deviceHistoryCommand = &cobra.Command{
+	Use:   "history [device id]",
+	Short: "Show online and offline transitions of device",
+	Args:  cobra.ExactArgs(1),
+	Run:   showDeviceHistory}
+
+var (
+	deviceName   string
+	deviceSecret string
+	deviceBindIP string
+)
+
+func init() {
+	deviceAllowCommand.Flags().StringVar(&deviceName, "name", "", "Device name")
+	deviceAllowCommand.Flags().StringVar(&deviceSecret, "secret", "", "Shared secret sent by device in dev_secret header or basic authentication, empty to remove")
+	deviceAllowCommand.Flags().StringVar(&deviceBindIP, "bind-ip", "", "Accept pushes only from this IP address, empty to remove")
+	deviceCommand.AddCommand(deviceListCommand)
+	deviceCommand.AddCommand(deviceAllowCommand)
+	deviceCommand.AddCommand(deviceBlockCommand)
+	deviceCommand.AddCommand(deviceHistoryCommand)
+	RootCmd.AddCommand(deviceCommand)
+}
+
+func openMigratedDatabase() *gorm.DB {
+	db, err := openDatabase(dsn)
+	if err != nil {
+		log.Fatalln("failed to connect database:", err)
+	}
+	if err := migrateDatabase(db); err != nil {
+		log.Fatalln("failed to migrate database:", err)
+	}
+	return db
+}
+
+func listDevices(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	var devices []Device
+	if err := db.Order("id").Find(&devices).Error; err != nil {
+		log.Fatalln(err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Device ID", "Name", "Model", "Status", "IP Address", "Bind IP", "Secret", "Online", "Last Seen At"})
+	for _, device := range devices {
+		secret := "no"
+		if device.SecretHash != "" {
+			secret = "yes"
+		}
+		online := "no"
+		if device.IsOnline {
+			online = "yes"
+		}
+		lastSeen := ""
+		if device.LastSeenAt.Valid {
+			lastSeen = device.LastSeenAt.Time.Local().Format("2006-01-02 15:04:05")
+		}
+		table.Append([]string{device.ID, device.Name, device.Model, device.Status, device.IPAddress, device.BindIP, secret, online, lastSeen})
+	}
+	table.Render()
+}
+
+func allowDevices(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	values := map[string]interface{}{"status": deviceApproved}
+	if cmd.Flags().Changed("name") {
+		values["name"] = deviceName
+	}
+	if cmd.Flags().Changed("secret") {
+		values["secret_hash"] = ""
+		if deviceSecret != "" {
+			values["secret_hash"] = hashSecret(deviceSecret)
+		}
+	}
+	if cmd.Flags().Changed("bind-ip") {
+		values["bind_ip"] = deviceBindIP
+	}
+	for _, deviceId := range args {
+		result := db.Model(&Device{}).Where("id = ?", deviceId).Updates(values)
+		if result.Error != nil {
+			log.Fatalln(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			device := Device{ID: deviceId, Mode: "push", Status: deviceApproved, Name: deviceName, BindIP: deviceBindIP}
+			if deviceSecret != "" {
+				device.SecretHash = hashSecret(deviceSecret)
+			}
+			if err := db.Create(&device).Error; err != nil {
+				log.Fatalln(err)
+			}
+		}
+		fmt.Printf("Device %s is allowed\n", deviceId)
+	}
+}
+
+func blockDevices(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	for _, deviceId := range args {
+		result := db.Model(&Device{}).Where("id = ?", deviceId).Update("status", deviceBlocked)
+		if result.Error != nil {
+			log.Fatalln(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			log.Fatalf("device %s is not registered\n", deviceId)
+		}
+		fmt.Printf("Device %s is blocked\n", deviceId)
+	}
+}
+
+func showDeviceHistory(cmd *cobra.Command, args []string) {
+	db := openMigratedDatabase()
+	var changes []DeviceStatusChange
+	if err := db.Where("device_id = ?", args[0]).Order("id").Find(&changes).Error; err != nil {
+		log.Fatalln(err)
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Changed At", "Status", "Last Seen At", "Duration"})
+	for i, change := range changes {
+		status := "offline"
+		if change.Online {
+			status = "online"
+		}
+		lastSeen := ""
+		if change.LastSeenAt.Valid {
+			lastSeen = change.LastSeenAt.Time.Local().Format("2006-01-02 15:04:05")
+		}
+		// time spent in this status until the next transition
+		until := time.Now()
+		if i+1 < len(changes) {
+			until = changes[i+1].ChangedAt
+		}
+		table.Append([]string{change.ChangedAt.Local().Format("2006-01-02 15:04:05"), status, lastSeen, until.Sub(change.ChangedAt).Round(time.Second).String()})
+	}
+	table.Render()
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Maximum request body size, enroll data contains photo, face and fingerprint templates
+const maxRequestSize = 32 * 1024 * 1024
+
+// Handle log and enroll data pushed by machines using shared database connection pool,
+// requests are stored through ingestion queue when journal is enabled
+type pushHandler struct {
+	db    *gorm.DB
+	auth  *deviceAuth
+	queue *ingestQueue
+}
+
+// Error with HTTP status code returned to machine
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &requestError{status: http.StatusBadRequest, err: fmt.Errorf(format, a...)}
+}
+
+func (handler *pushHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	begin := time.Now()
+	deviceId := request.Header.Get("dev_id")
+	requestCode := request.Header.Get("request_code")
+	remoteHost, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		remoteHost = request.RemoteAddr
+	}
+	request.Body = http.MaxBytesReader(writer, request.Body, maxRequestSize)
+	err = handler.handle(request.Context(), request, deviceId, requestCode, remoteHost)
+	status := http.StatusOK
+	if err != nil {
+		status = handler.statusOf(request.Context(), err)
+		logEvent("error", "request failed", "dev_id", deviceId, "request_code", requestCode, "remote", remoteHost, "status", status, "error", err)
+		writer.Header()["response_code"] = []string{"ERROR"}
+		writer.WriteHeader(status)
+		return
+	}
+	// deliver queued command in the response, the device reports its result with send_cmd_result request
+	command, err := nextCommand(handler.db.WithContext(request.Context()), deviceId)
+	if err != nil {
+		logEvent("warning", "read command queue failed", "dev_id", deviceId, "error", err)
+	}
+	logEvent("info", "request handled", "dev_id", deviceId, "request_code", requestCode, "remote", remoteHost, "status", status, "duration", time.Since(begin))
+	writer.Header()["response_code"] = []string{"OK"}
+	if command == nil {
+		transId := request.Header.Get("trans_id")
+		if transId == "" {
+			transId = "100"
+		}
+		writer.Header()["trans_id"] = []string{transId}
+		writer.WriteHeader(status)
+		return
+	}
+	logEvent("info", "command delivered", "dev_id", deviceId, "trans_id", command.ID, "cmd_code", command.Code, "attempts", command.Attempts)
+	writer.Header()["trans_id"] = []string{strconv.Itoa(int(command.ID))}
+	writer.Header()["cmd_code"] = []string{command.Code}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write([]byte(command.Payload))
+}
+
+// Map error to HTTP status code
+func (handler *pushHandler) statusOf(ctx context.Context, err error) int {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.status
+	}
+	// database is unreachable, machine should retry later
+	if sqlDB, dbErr := handler.db.DB(); dbErr != nil || sqlDB.PingContext(ctx) != nil {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func (handler *pushHandler) handle(ctx context.Context, request *http.Request, deviceId string, requestCode string, remoteHost string) error {
+	if request.Method != http.MethodPost {
+		return &requestError{status: http.StatusMethodNotAllowed, err: fmt.Errorf("method %s is not allowed", request.Method)}
+	}
+	if deviceId == "" {
+		return badRequest("dev_id header is required")
+	}
+	if err := handler.auth.authorize(ctx, request, deviceId, remoteHost); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return &requestError{status: http.StatusRequestEntityTooLarge, err: err}
+	}
+	if heartbeatRequestCodes[requestCode] {
+		// nothing to store but the last seen time, not worth retrying
+		if err := saveDevice(handler.db.WithContext(ctx), deviceId, request.Header.Get("dev_model"), remoteHost, time.Now()); err != nil {
+			logEvent("warning", "update last seen time failed", "dev_id", deviceId, "error", err)
+		}
+		return nil
+	}
+	if requestCode == "send_cmd_result" {
+		if err := completeCommand(handler.db.WithContext(ctx), deviceId, request.Header.Get("trans_id"), request.Header.Get("cmd_return_code"), body); err != nil {
+			return err
+		}
+	}
+	entry := journalEntry{
+		DeviceID:    deviceId,
+		Model:       request.Header.Get("dev_model"),
+		Remote:      remoteHost,
+		RequestCode: requestCode,
+		ReceivedAt:  time.Now()}
+	if requestCode == "realtime_enroll_data" || requestCode == "realtime_glog" {
+		entry.Body = body
+	}
+	// reject invalid data before it is acknowledged
+	data, err := parseEntry(entry)
+	if err != nil {
+		return err
+	}
+	if handler.queue != nil {
+		if err := handler.queue.enqueue(entry); err != nil {
+			return &requestError{status: http.StatusServiceUnavailable, err: err}
+		}
+	} else if err := storeEntries(handler.db.WithContext(ctx), []journalEntry{entry}); err != nil {
+		return err
+	}
+	if enroll, ok := data.(EnrollJson); ok {
+		go replicateEnroll(remoteHost, enroll)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500"
+	"gorm.io/gorm"
+)
+
+// Number of rows inserted in one statement
+const insertBatchSize = 100
+
+// Log parsed from pushed request, ready to be stored
+type pendingLog struct {
+	record Log
+	photo  string
+}
+
+func (pending pendingLog) key() string {
+	return logKey(pending.record)
+}
+
+// Natural key of log, see unique index of Log
+func logKey(record Log) string {
+	return fmt.Sprintf("%s|%d|%d|%s", record.DeviceID, record.UserID, record.Time.Unix(), record.VerifyMode)
+}
+
+// Parse pushed request body, errors are reported as bad request
+func parseEntry(entry journalEntry) (interface{}, error) {
+	switch entry.RequestCode {
+	case "realtime_enroll_data":
+		var enroll EnrollJson
+		if err := json.Unmarshal(entry.Body, &enroll); err != nil {
+			return nil, badRequest("invalid enroll data: %v", err)
+		}
+		if _, err := strconv.Atoi(enroll.UserID); err != nil {
+			return nil, badRequest("invalid user id %q", enroll.UserID)
+		}
+		return enroll, nil
+	case "realtime_glog":
+		var glog LogJson
+		if err := json.Unmarshal(entry.Body, &glog); err != nil {
+			return nil, badRequest("invalid log data: %v", err)
+		}
+		datetime, err := time.ParseInLocation("20060102150405", glog.Time, time.Local)
+		if err != nil {
+			return nil, badRequest("invalid log time %q", glog.Time)
+		}
+		userId, err := strconv.Atoi(glog.UserID)
+		if err != nil {
+			return nil, badRequest("invalid user id %q", glog.UserID)
+		}
+		record := Log{Time: datetime, UserID: uint(userId), DeviceID: entry.DeviceID, DoorMode: glog.DoorMode, InOut: glog.InOut, IoMode: glog.IoMode, VerifyMode: glog.VerifyMode}
+		return pendingLog{record: record, photo: glog.LogPhoto}, nil
+	}
+	return nil, nil
+}
+
+// Store pushed requests, devices are updated once with their latest request,
+// enrolled users are stored in order of arrival and logs of all requests are inserted in batches
+func storeEntries(db *gorm.DB, entries []journalEntry) error {
+	latest := make(map[string]journalEntry)
+	deviceIds := make([]string, 0)
+	for _, entry := range entries {
+		if _, ok := latest[entry.DeviceID]; !ok {
+			deviceIds = append(deviceIds, entry.DeviceID)
+		}
+		latest[entry.DeviceID] = entry
+	}
+	for _, deviceId := range deviceIds {
+		entry := latest[deviceId]
+		if err := saveDevice(db, entry.DeviceID, entry.Model, entry.Remote, entry.ReceivedAt); err != nil {
+			return err
+		}
+	}
+	logs := make([]pendingLog, 0, len(entries))
+	for _, entry := range entries {
+		data, err := parseEntry(entry)
+		if err != nil {
+			return err
+		}
+		switch value := data.(type) {
+		case EnrollJson:
+			if err := saveEnroll(db, value); err != nil {
+				return err
+			}
+		case pendingLog:
+			logs = append(logs, value)
+		}
+	}
+	return saveLogs(db, logs)
+}
+
+// Register new device pushing to this server or update address, model and the time existing device was last seen,
+// mode and port of existing device are kept as registered.
+// Offline device is marked online again unless the request is replayed after it went offline.
+func saveDevice(db *gorm.DB, deviceId string, model string, ipAddress string, seenAt time.Time) error {
+	var device Device
+	result := db.Limit(1).Find(&device, "id = ?", deviceId)
+	if result.Error != nil {
+		return result.Error
+	}
+	lastSeenAt := sql.NullTime{Time: seenAt, Valid: true}
+	if result.RowsAffected == 0 {
+		device = Device{ID: deviceId, Model: model, IPAddress: ipAddress, Port: port, IsOnline: true, Mode: "push", Status: deviceApproved, LastSeenAt: lastSeenAt}
+		if err := db.Create(&device).Error; err != nil {
+			return err
+		}
+		return recordStatusChange(db, deviceId, true, lastSeenAt)
+	}
+	if device.LastSeenAt.Valid && !seenAt.After(device.LastSeenAt.Time) {
+		// replayed request is older than the last seen time
+		lastSeenAt = device.LastSeenAt
+	}
+	if err := db.Model(&device).Updates(Device{Model: model, IPAddress: ipAddress, LastSeenAt: lastSeenAt}).Error; err != nil {
+		return err
+	}
+	if !device.IsOnline && time.Since(lastSeenAt.Time) < offlineAfter {
+		return setDeviceOnline(db, deviceId, true, lastSeenAt)
+	}
+	return nil
+}
+
+// Create or update enrolled user
+func saveEnroll(db *gorm.DB, enroll EnrollJson) error {
+	userId, err := strconv.Atoi(enroll.UserID)
+	if err != nil {
+		return badRequest("invalid user id %q", enroll.UserID)
+	}
+	var fingerprints string
+	var timeGroups string
+	if fps, err := json.Marshal(enroll.Fingerprints); err == nil && len(fps) > 0 {
+		fingerprints = string(fps)
+	}
+	if tgrs, err := json.Marshal(enroll.TimeGroups); err == nil && len(tgrs) > 0 {
+		timeGroups = string(tgrs)
+	}
+	values := User{
+		Number:       enroll.UserNumber,
+		Name:         enroll.Name,
+		Privilage:    uint16(enroll.Privilage),
+		Photo:        enroll.Photo,
+		Card:         enroll.Card,
+		Fingerprints: fingerprints,
+		Face:         enroll.Face,
+		Password:     enroll.Password,
+		ValidStart:   enroll.ValidStart,
+		ValidEnd:     enroll.ValidEnd,
+		TimeGroups:   timeGroups}
+	var user User
+	result := db.Limit(1).Find(&user, userId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		values.ID = uint(userId)
+		return db.Create(&values).Error
+	}
+	return db.Model(&user).Updates(values).Error
+}
+
+// Store logs and their photos, unknown users are registered using user id as name.
+// Logs already stored with the same device, user, time and verify mode are ignored
+// since machines retry pushing logs when they don't get a timely reply.
+func saveLogs(db *gorm.DB, logs []pendingLog) error {
+	logs, err := newLogs(db, logs)
+	if err != nil || len(logs) == 0 {
+		return err
+	}
+	for i := range logs {
+		record := logs[i].record
+		if photoDir != "" && logs[i].photo != "" {
+			userId := strconv.Itoa(int(record.UserID))
+			if path, err := sf3500.SavePhoto(photoDir, sf3500.PhotoFileName(record.DeviceID, userId, record.Time), logs[i].photo); err == nil {
+				logs[i].photo = path
+			} else {
+				logEvent("warning", "save log photo failed, photo is stored in database", "dev_id", record.DeviceID, "user_id", userId, "error", err)
+			}
+		}
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := createMissingUsers(tx, logs); err != nil {
+			return err
+		}
+		records := make([]Log, len(logs))
+		for i := range logs {
+			records[i] = logs[i].record
+		}
+		if err := tx.CreateInBatches(&records, insertBatchSize).Error; err != nil {
+			return err
+		}
+		photos := make([]LogPhoto, len(records))
+		for i := range records {
+			photos[i] = LogPhoto{LogID: records[i].ID, Photo: logs[i].photo}
+		}
+		return tx.CreateInBatches(&photos, insertBatchSize).Error
+	})
+	if err != nil {
+		// the same logs may be stored by concurrent request, rejected by unique index
+		if remaining, checkErr := newLogs(db, logs); checkErr == nil && len(remaining) == 0 {
+			return nil
+		}
+	}
+	return err
+}
+
+// Remove logs repeated in the batch or already stored in database
+func newLogs(db *gorm.DB, logs []pendingLog) ([]pendingLog, error) {
+	if len(logs) == 0 {
+		return logs, nil
+	}
+	deviceIds := make([]string, 0)
+	seen := make(map[string]bool)
+	begin, end := logs[0].record.Time, logs[0].record.Time
+	for _, pending := range logs {
+		if !seen[pending.record.DeviceID] {
+			seen[pending.record.DeviceID] = true
+			deviceIds = append(deviceIds, pending.record.DeviceID)
+		}
+		if pending.record.Time.Before(begin) {
+			begin = pending.record.Time
+		}
+		if pending.record.Time.After(end) {
+			end = pending.record.Time
+		}
+	}
+	var stored []Log
+	err := db.Model(&Log{}).
+		Select("device_id", "user_id", "time", "verify_mode").
+		Where("device_id IN ? AND time BETWEEN ? AND ?", deviceIds, begin, end).
+		Find(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(stored))
+	for _, record := range stored {
+		exists[logKey(record)] = true
+	}
+	result := make([]pendingLog, 0, len(logs))
+	for _, pending := range logs {
+		key := pending.key()
+		if exists[key] {
+			continue
+		}
+		exists[key] = true
+		result = append(result, pending)
+	}
+	return result, nil
+}
+
+// Register users of logs that are not enrolled yet using user id as name
+func createMissingUsers(tx *gorm.DB, logs []pendingLog) error {
+	userIds := make([]uint, 0)
+	seen := make(map[uint]bool)
+	for _, pending := range logs {
+		if !seen[pending.record.UserID] {
+			seen[pending.record.UserID] = true
+			userIds = append(userIds, pending.record.UserID)
+		}
+	}
+	var existing []uint
+	if err := tx.Unscoped().Model(&User{}).Where("id IN ?", userIds).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	for _, id := range existing {
+		delete(seen, id)
+	}
+	users := make([]User, 0, len(seen))
+	for _, id := range userIds {
+		if seen[id] {
+			users = append(users, User{ID: id, Name: strconv.Itoa(int(id)), Privilage: 0})
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&users, insertBatchSize).Error
+}
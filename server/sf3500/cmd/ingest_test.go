@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveDeviceKeepsModeAndPort(t *testing.T) {
+	db := newTestDatabase(t)
+	// device registered for pulling logs
+	if err := db.Create(&Device{ID: "1", Model: "SF3500", IPAddress: "10.0.0.1", Port: 5005, Mode: "pull", Status: deviceApproved}).Error; err != nil {
+		t.Fatal(err)
+	}
+	seenAt := time.Now()
+	if err := saveDevice(db, "1", "SF3500A", "10.0.0.2", seenAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveDevice(db, "2", "SF3500", "10.0.0.3", seenAt); err != nil {
+		t.Fatal(err)
+	}
+	var pulled, pushed Device
+	if err := db.First(&pulled, "id = ?", "1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&pushed, "id = ?", "2").Error; err != nil {
+		t.Fatal(err)
+	}
+	if pulled.Mode != "pull" || pulled.Port != 5005 {
+		t.Errorf("mode, port = %s, %d after push, want pull, 5005", pulled.Mode, pulled.Port)
+	}
+	if pulled.Model != "SF3500A" || pulled.IPAddress != "10.0.0.2" || !pulled.LastSeenAt.Time.Equal(seenAt) {
+		t.Errorf("model, ip address, last seen = %s, %s, %v, want values of push", pulled.Model, pulled.IPAddress, pulled.LastSeenAt.Time)
+	}
+	if pushed.Mode != "push" || pushed.Port != port {
+		t.Errorf("mode, port of new device = %s, %d, want push, %d", pushed.Mode, pushed.Port, port)
+	}
+}
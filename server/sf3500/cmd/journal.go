@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum size of a journal segment before a new segment is started
+const journalSegmentSize = 64 * 1024 * 1024
+
+// Pushed request appended to journal before it is acknowledged
+type journalEntry struct {
+	Seq         uint64          `json:"seq"`
+	DeviceID    string          `json:"dev_id"`
+	Model       string          `json:"dev_model"`
+	Remote      string          `json:"remote"`
+	RequestCode string          `json:"request_code"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+}
+
+// Write-ahead journal of pushed requests.
+// Entries are appended as json lines to segment files named by their first sequence number,
+// a checkpoint file keeps the last sequence number stored in database.
+// Segments are never reopened for writing, so a torn line after crash is only found at the end of a segment.
+type journal struct {
+	dir        string
+	mutex      sync.Mutex
+	file       *os.File
+	size       int64
+	nextSeq    uint64
+	checkpoint uint64
+	closed     bool
+	notify     chan struct{}
+}
+
+func segmentName(firstSeq uint64) string {
+	return fmt.Sprintf("journal-%020d.log", firstSeq)
+}
+
+// Open journal in directory, a new segment is started after the last written entry
+func openJournal(dir string) (*journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	j := &journal{dir: dir, notify: make(chan struct{}, 1)}
+	checkpoint, err := j.readCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	j.checkpoint = checkpoint
+	segments, err := j.segments()
+	if err != nil {
+		return nil, err
+	}
+	lastSeq := checkpoint
+	if len(segments) > 0 {
+		if err := readSegment(filepath.Join(dir, segmentName(segments[len(segments)-1])), func(entry journalEntry) error {
+			if entry.Seq > lastSeq {
+				lastSeq = entry.Seq
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if first := segments[len(segments)-1]; first > lastSeq+1 {
+			lastSeq = first - 1
+		}
+	}
+	j.nextSeq = lastSeq + 1
+	if err := j.startSegment(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// List first sequence numbers of segments in ascending order
+func (j *journal) segments() ([]uint64, error) {
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]uint64, 0)
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "journal-") || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		if seq, err := strconv.ParseUint(name[len("journal-"):len(name)-len(".log")], 10, 64); err == nil {
+			segments = append(segments, seq)
+		}
+	}
+	sort.Slice(segments, func(i, k int) bool { return segments[i] < segments[k] })
+	return segments, nil
+}
+
+func (j *journal) startSegment() error {
+	file, err := os.OpenFile(filepath.Join(j.dir, segmentName(j.nextSeq)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file = file
+	j.size = 0
+	return nil
+}
+
+// Append entry and flush it to disk, the entry sequence number is assigned by journal
+func (j *journal) append(entry journalEntry) (uint64, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.closed {
+		return 0, errors.New("journal is closed")
+	}
+	if j.size >= journalSegmentSize {
+		if err := j.startSegment(); err != nil {
+			return 0, err
+		}
+	}
+	entry.Seq = j.nextSeq
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	if err := j.file.Sync(); err != nil {
+		return 0, err
+	}
+	j.nextSeq++
+	select {
+	case j.notify <- struct{}{}:
+	default:
+	}
+	return entry.Seq, nil
+}
+
+// Stop accepting new entries, readers stop after reading all written entries
+func (j *journal) close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+	close(j.notify)
+	return j.file.Close()
+}
+
+func (j *journal) isClosed() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.closed
+}
+
+func (j *journal) readCheckpoint() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(j.dir, "checkpoint"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Save last sequence number stored in database and remove segments that are completely stored
+func (j *journal) saveCheckpoint(seq uint64) error {
+	path := filepath.Join(j.dir, "checkpoint")
+	if err := os.WriteFile(path+".tmp", []byte(strconv.FormatUint(seq, 10)), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	j.checkpoint = seq
+	j.mutex.Unlock()
+	segments, err := j.segments()
+	if err != nil {
+		return err
+	}
+	// a segment is completely stored when the next segment starts at or before checkpoint + 1
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i+1] <= seq+1 {
+			os.Remove(filepath.Join(j.dir, segmentName(segments[i])))
+		}
+	}
+	return nil
+}
+
+// Read all complete entries of a segment file, invalid lines are skipped
+func readSegment(path string, fn func(entry journalEntry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry journalEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr == nil {
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Follow journal entries from checkpoint, waiting for new entries when all written entries are read
+type journalReader struct {
+	journal *journal
+	segment uint64
+	file    *os.File
+	reader  *bufio.Reader
+	partial []byte
+	minSeq  uint64
+	// segment is read to the end after the writer left it, so no line of it is still being written
+	drained bool
+}
+
+func (j *journal) newReader() *journalReader {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return &journalReader{journal: j, minSeq: j.checkpoint + 1}
+}
+
+// Open the segment containing the next entry to read
+func (r *journalReader) openNext() (bool, error) {
+	segments, err := r.journal.segments()
+	if err != nil {
+		return false, err
+	}
+	for i, first := range segments {
+		if r.file != nil && first <= r.segment {
+			continue
+		}
+		// skip segments completely before the entry to read
+		if r.file == nil && i+1 < len(segments) && segments[i+1] <= r.minSeq {
+			continue
+		}
+		file, err := os.Open(filepath.Join(r.journal.dir, segmentName(first)))
+		if err != nil {
+			return false, err
+		}
+		if r.file != nil {
+			r.file.Close()
+		}
+		r.file = file
+		r.segment = first
+		r.reader = bufio.NewReader(file)
+		r.partial = nil
+		r.drained = false
+		return true, nil
+	}
+	return false, nil
+}
+
+// Whether the writer started a segment after the segment being read
+func (r *journalReader) newerSegment() (bool, error) {
+	segments, err := r.journal.segments()
+	if err != nil {
+		return false, err
+	}
+	return len(segments) > 0 && segments[len(segments)-1] > r.segment, nil
+}
+
+// Read next entry. Returns false when timeout elapsed without new entry,
+// io.EOF is returned after journal is closed and all entries are read.
+func (r *journalReader) next(timeout <-chan time.Time) (journalEntry, bool, error) {
+	for {
+		if r.file == nil {
+			if ok, err := r.openNext(); err != nil {
+				return journalEntry{}, false, err
+			} else if !ok {
+				return journalEntry{}, false, io.EOF
+			}
+		}
+		line, err := r.reader.ReadBytes('\n')
+		r.partial = append(r.partial, line...)
+		if err == nil {
+			var entry journalEntry
+			jsonErr := json.Unmarshal(bytes.TrimSpace(r.partial), &entry)
+			r.partial = nil
+			if jsonErr != nil {
+				logEvent("warning", "invalid journal entry skipped", "segment", segmentName(r.segment), "error", jsonErr)
+				continue
+			}
+			if entry.Seq < r.minSeq {
+				continue
+			}
+			r.minSeq = entry.Seq + 1
+			return entry, true, nil
+		}
+		if err != io.EOF {
+			return journalEntry{}, false, err
+		}
+		// Writer may be in the middle of a line. After it moved to a newer segment or closed the journal,
+		// this segment is read once more, the rest of the segment after that is a torn line.
+		closed := r.journal.isClosed()
+		newer, err := r.newerSegment()
+		if err != nil {
+			return journalEntry{}, false, err
+		}
+		if (closed || newer) && !r.drained {
+			r.drained = true
+			continue
+		}
+		if newer {
+			if len(r.partial) > 0 {
+				logEvent("warning", "torn journal entry skipped", "segment", segmentName(r.segment))
+			}
+			if _, err := r.openNext(); err != nil {
+				return journalEntry{}, false, err
+			}
+			continue
+		}
+		if closed {
+			return journalEntry{}, false, io.EOF
+		}
+		select {
+		case <-r.journal.notify:
+		case <-timeout:
+			return journalEntry{}, false, nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (r *journalReader) close() {
+	if r.file != nil {
+		r.file.Close()
+	}
+}
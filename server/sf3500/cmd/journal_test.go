@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func appendEntries(t *testing.T, j *journal, deviceIds ...string) {
+	t.Helper()
+	for _, deviceId := range deviceIds {
+		if _, err := j.append(journalEntry{DeviceID: deviceId, RequestCode: "realtime_glog", ReceivedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Read entries until all written entries are read
+func readEntries(t *testing.T, j *journal) []uint64 {
+	t.Helper()
+	reader := j.newReader()
+	defer reader.close()
+	seqs := make([]uint64, 0)
+	for {
+		entry, ok, err := reader.next(time.After(100 * time.Millisecond))
+		if err == io.EOF || (err == nil && !ok) {
+			return seqs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, entry.Seq)
+	}
+}
+
+func TestJournalReplayAfterCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, j, "1", "2", "3", "4")
+	if err := j.saveCheckpoint(2); err != nil {
+		t.Fatal(err)
+	}
+	j.close()
+
+	// entries after checkpoint are replayed, sequence continues after the last entry
+	j, err = openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	appendEntries(t, j, "5")
+	if seqs := readEntries(t, j); !reflect.DeepEqual(seqs, []uint64{3, 4, 5}) {
+		t.Errorf("replayed entries = %v, want [3 4 5]", seqs)
+	}
+}
+
+func TestJournalSkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, j, "1", "2")
+	j.close()
+	// crash while writing the third entry
+	file, err := os.OpenFile(filepath.Join(dir, segmentName(1)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":3,"dev_id":"3","request_`)
+	file.Close()
+
+	j, err = openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	appendEntries(t, j, "3")
+	if seqs := readEntries(t, j); !reflect.DeepEqual(seqs, []uint64{1, 2, 3}) {
+		t.Errorf("entries = %v, want [1 2 3]", seqs)
+	}
+}
+
+func TestJournalReadsLineFinishedBeforeRotation(t *testing.T) {
+	dir := t.TempDir()
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	appendEntries(t, j, "1")
+	line := `{"seq":2,"dev_id":"2","request_code":"realtime_glog"}` + "\n"
+	file, err := os.OpenFile(filepath.Join(dir, segmentName(1)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	// reader reaches the end of segment in the middle of the line written by the writer holding the lock
+	file.WriteString(line[:20])
+	reader := j.newReader()
+	defer reader.close()
+	j.mutex.Lock()
+	result := make(chan []uint64)
+	go func() {
+		seqs := make([]uint64, 0)
+		for len(seqs) < 3 {
+			entry, ok, err := reader.next(time.After(5 * time.Second))
+			if err != nil || !ok {
+				break
+			}
+			seqs = append(seqs, entry.Seq)
+		}
+		result <- seqs
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// writer finishes the line and starts a new segment
+	file.WriteString(line[20:])
+	j.nextSeq = 3
+	if err := j.startSegment(); err != nil {
+		t.Fatal(err)
+	}
+	j.mutex.Unlock()
+	appendEntries(t, j, "3")
+	if seqs := <-result; !reflect.DeepEqual(seqs, []uint64{1, 2, 3}) {
+		t.Errorf("entries = %v, want [1 2 3]", seqs)
+	}
+}
+
+func TestJournalCheckpointRemovesStoredSegments(t *testing.T) {
+	dir := t.TempDir()
+	// every open starts a new segment
+	for _, deviceIds := range [][]string{{"1", "2"}, {"3", "4"}, {"5"}} {
+		j, err := openJournal(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendEntries(t, j, deviceIds...)
+		j.close()
+	}
+	j, err := openJournal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	if err := j.saveCheckpoint(3); err != nil {
+		t.Fatal(err)
+	}
+	segments, err := j.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// segment 3 still has entry 4, the empty segment 6 is written by the open journal
+	if !reflect.DeepEqual(segments, []uint64{3, 5, 6}) {
+		t.Errorf("segments = %v, want [3 5 6]", segments)
+	}
+	checkpoint, err := j.readCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint != 3 {
+		t.Errorf("checkpoint = %d, want 3", checkpoint)
+	}
+	if seqs := readEntries(t, j); !reflect.DeepEqual(seqs, []uint64{4, 5}) {
+		t.Errorf("entries = %v, want [4 5]", seqs)
+	}
+}
+
+func TestCheckpointTrackerWaitsForEarlierBatches(t *testing.T) {
+	j, err := openJournal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+	tracker := &checkpointTracker{journal: j, done: make(map[uint64]bool)}
+	first := []journalEntry{{Seq: 1}, {Seq: 2}}
+	second := []journalEntry{{Seq: 3}}
+	tracker.dispatched(first)
+	tracker.dispatched(second)
+
+	steps := []struct {
+		batch      []journalEntry
+		checkpoint uint64
+	}{
+		// later batch stored first does not move checkpoint over entries not stored yet
+		{second, 0},
+		{first, 3},
+	}
+	for _, step := range steps {
+		tracker.stored(step.batch)
+		checkpoint, err := j.readCheckpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checkpoint != step.checkpoint {
+			t.Errorf("checkpoint = %d after storing %v, want %d", checkpoint, step.batch, step.checkpoint)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Device struct {
+	ID         string `gorm:"size:20;primaryKey"`
+	Name       string `gorm:"size:255"`
+	Model      string `gorm:"size:255"`
+	Mode       string `gorm:"size:10"` // push or pull
+	IsOnline   bool
+	IPAddress  string `gorm:"size:255"` // IP address or host name
+	Port       uint16
+	MachineID  uint16 // machine number of SF3000 and RAC2000 in pull mode
+	Password   string `gorm:"size:255"`
+	Status     string `gorm:"size:10;index"` // approved, pending or blocked
+	SecretHash string `gorm:"size:64"`       // sha256 of shared secret, empty if not required
+	BindIP     string `gorm:"size:45"`       // accept pushes only from this address if not empty
+	LastSeenAt sql.NullTime
+	Logs       []Log
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+type User struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement:false"`
+	Number       string `gorm:"size:20"`
+	Name         string `gorm:"size:200;not null"`
+	Privilage    uint16 `gorm:"not null"`
+	Photo        string
+	Card         string `gorm:"size:10;index"`
+	Fingerprints string
+	Face         string
+	Password     string `gorm:"size:255"`
+	ValidStart   string `gorm:"size:8"`
+	ValidEnd     string `gorm:"size:8"`
+	TimeGroups   string
+	Logs         []Log
+	ActivatedAt  sql.NullTime
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// type UserTimezone struct {
+// 	UserID    uint   `gorm:"primaryKey"`
+// 	Day       string `gorm:"primaryKey"`
+// 	Number    uint16 `gorm:"primaryKey"`
+// 	TimeStart time.Time
+// 	TimeEnd   time.Time
+// 	CreatedAt time.Time
+// 	UpdatedAt time.Time
+// }
+
+// Log is identified by device, user, time and verify mode,
+// machines may push the same log more than once
+type Log struct {
+	ID         uint
+	DeviceID   string    `gorm:"size:20;uniqueIndex:idx_logs_natural_key,priority:1"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_logs_natural_key,priority:2"`
+	Time       time.Time `gorm:"not null;uniqueIndex:idx_logs_natural_key,priority:3"`
+	VerifyMode string    `gorm:"size:20;uniqueIndex:idx_logs_natural_key,priority:4"`
+	IoMode     uint16    `gorm:"size:20"`
+	InOut      string    `gorm:"size:5;not null"`
+	DoorMode   string    `gorm:"size:20"`
+	LogPhoto   LogPhoto
+	CreatedAt  time.Time
+}
+
+type LogPhoto struct {
+	gorm.Model
+	LogID     uint
+	Photo     string
+	CreatedAt time.Time
+}
+
+// Online or offline transition of a device
+type DeviceStatusChange struct {
+	ID         uint
+	DeviceID   string    `gorm:"size:20;not null;index:idx_device_status_changes_device,priority:1"`
+	Online     bool      `gorm:"not null"`
+	ChangedAt  time.Time `gorm:"not null;index:idx_device_status_changes_device,priority:2"`
+	LastSeenAt sql.NullTime
+}
+
+// Command queued for a device, delivered in response of the next request pushed by the device.
+// The command id is sent as trans_id and reported back by the device with the result.
+type DeviceCommand struct {
+	ID          uint
+	DeviceID    string `gorm:"size:20;not null;index:idx_device_commands_status,priority:1"`
+	Kind        string `gorm:"size:20;not null"` // set_user, delete_user, set_time, open_door or clear_logs
+	Code        string `gorm:"size:50;not null"` // command name sent to device
+	Payload     string // command sent to device, set_time without time is built when delivered
+	Status      string `gorm:"size:10;not null;index:idx_device_commands_status,priority:2"` // queued, sent, done, failed or canceled
+	Attempts    int
+	ResultCode  sql.NullInt32
+	Result      string
+	SentAt      sql.NullTime
+	CompletedAt sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type LogJson struct {
+	UserID     string `json:"userId"`
+	Time       string `json:"time"`
+	VerifyMode string `json:"verifyMode"`
+	IoMode     uint16 `json:"ioMode"`
+	InOut      string `json:"inOut"`
+	DoorMode   string `json:"doorMode"`
+	LogPhoto   string `json:"logPhoto"`
+}
+
+type EnrollJson struct {
+	UserID       string     `json:"userId"`
+	UserNumber   string     `json:"userNo"`
+	Name         string     `json:"name"`
+	Privilage    int        `json:"privilege"`
+	Photo        string     `json:"photo"`
+	Card         string     `json:"card"`
+	Fingerprints []string   `json:"fps"`
+	Face         string     `json:"face"`
+	Password     string     `json:"pwd"`
+	ValidStart   string     `json:"vaildStart"`
+	ValidEnd     string     `json:"vaildEnd"`
+	TimeGroups   TimeGroups `json:"timeGroups"`
+}
+
+type TimeGroups struct {
+	Sunday   []string `json:"sun"`
+	Monday   []string `json:"mon"`
+	Tuesday  []string `json:"tue"`
+	Wedneday []string `json:"wed"`
+	Thursday []string `json:"thu"`
+	Friday   []string `json:"fri"`
+	Saturday []string `json:"sat"`
+}
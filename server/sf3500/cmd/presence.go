@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Request codes sent by devices only to keep in contact with the server,
+// they update the last seen time without being journaled
+var heartbeatRequestCodes = map[string]bool{
+	"receive_cmd": true,
+	"heartbeat":   true,
+	"keep_alive":  true,
+}
+
+// Change online status of device and record the transition,
+// nothing is recorded when the status was already changed by concurrent request
+func setDeviceOnline(db *gorm.DB, deviceId string, online bool, lastSeenAt sql.NullTime) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Device{}).Where("id = ? AND is_online = ?", deviceId, !online).Update("is_online", online)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordStatusChange(tx, deviceId, online, lastSeenAt)
+	})
+}
+
+func recordStatusChange(db *gorm.DB, deviceId string, online bool, lastSeenAt sql.NullTime) error {
+	if online {
+		logEvent("info", "device online", "dev_id", deviceId)
+	} else {
+		logEvent("warning", "device offline", "dev_id", deviceId, "silence", offlineAfter)
+	}
+	return db.Create(&DeviceStatusChange{DeviceID: deviceId, Online: online, ChangedAt: time.Now(), LastSeenAt: lastSeenAt}).Error
+}
+
+// Mark online devices not seen within offline duration as offline
+func markOfflineDevices(db *gorm.DB) error {
+	var devices []Device
+	err := db.Select("id", "last_seen_at").
+		Where("is_online = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", true, time.Now().Add(-offlineAfter)).
+		Find(&devices).Error
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if err := setDeviceOnline(db, device.ID, false, device.LastSeenAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check silent devices periodically until context is done
+func monitorDevices(ctx context.Context, db *gorm.DB) {
+	interval := offlineAfter / 4
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	} else if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := markOfflineDevices(db.WithContext(ctx)); err != nil && ctx.Err() == nil {
+			logEvent("error", "check offline devices failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
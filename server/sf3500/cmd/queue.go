@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ingestion queue, pushed requests are acknowledged after appended to journal
+// and stored to database in batches by worker pool.
+// Entries of a device are always stored by the same worker, so they are stored in order of arrival.
+// Entries not stored yet are replayed from journal when the server is restarted.
+type ingestQueue struct {
+	db        *gorm.DB
+	journal   *journal
+	workers   int
+	batchSize int
+	batchWait time.Duration
+	batches   []chan []journalEntry
+	tracker   *checkpointTracker
+	stopping  chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newIngestQueue(db *gorm.DB, dir string, workers int, batchSize int, batchWait time.Duration) (*ingestQueue, error) {
+	j, err := openJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batches := make([]chan []journalEntry, workers)
+	for i := range batches {
+		batches[i] = make(chan []journalEntry, 1)
+	}
+	return &ingestQueue{
+		db:        db,
+		journal:   j,
+		workers:   workers,
+		batchSize: batchSize,
+		batchWait: batchWait,
+		batches:   batches,
+		stopping:  make(chan struct{}),
+		tracker:   &checkpointTracker{journal: j, done: make(map[uint64]bool)}}, nil
+}
+
+// Start dispatcher and workers, entries written before restart are processed first
+func (queue *ingestQueue) start() {
+	queue.wg.Add(1)
+	go queue.dispatch()
+	for _, batches := range queue.batches {
+		queue.wg.Add(1)
+		go queue.work(batches)
+	}
+}
+
+// Append pushed request to journal
+func (queue *ingestQueue) enqueue(entry journalEntry) error {
+	_, err := queue.journal.append(entry)
+	return err
+}
+
+// Stop accepting new entries and wait until all journal entries are stored.
+// When timeout elapsed, retrying unreachable database is given up
+// and entries not stored yet are replayed on next start.
+func (queue *ingestQueue) close(timeout time.Duration) {
+	queue.journal.close()
+	finished := make(chan struct{})
+	go func() {
+		queue.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		close(queue.stopping)
+		<-finished
+	}
+}
+
+// Wait before retrying, returns false if queue is stopping
+func (queue *ingestQueue) wait(backoff time.Duration) bool {
+	select {
+	case <-queue.stopping:
+		return false
+	case <-time.After(backoff):
+		return true
+	}
+}
+
+// Read journal and group entries into batches of workers
+func (queue *ingestQueue) dispatch() {
+	defer queue.wg.Done()
+	defer func() {
+		for _, batches := range queue.batches {
+			close(batches)
+		}
+	}()
+	reader := queue.journal.newReader()
+	defer reader.close()
+	for {
+		entry, _, err := reader.next(nil)
+		if err != nil {
+			if err != io.EOF {
+				logEvent("error", "read journal failed", "error", err)
+			}
+			return
+		}
+		batch := []journalEntry{entry}
+		timeout := time.After(queue.batchWait)
+		for len(batch) < queue.batchSize {
+			entry, ok, err := reader.next(timeout)
+			if err != nil || !ok {
+				break
+			}
+			batch = append(batch, entry)
+		}
+		queue.tracker.dispatched(batch)
+		for i, part := range queue.split(batch) {
+			if len(part) > 0 {
+				queue.batches[i] <- part
+			}
+		}
+	}
+}
+
+// Split batch into batches of workers by device id, keeping order of entries
+func (queue *ingestQueue) split(batch []journalEntry) [][]journalEntry {
+	parts := make([][]journalEntry, queue.workers)
+	for _, entry := range batch {
+		hash := fnv.New32a()
+		hash.Write([]byte(entry.DeviceID))
+		i := int(hash.Sum32() % uint32(queue.workers))
+		parts[i] = append(parts[i], entry)
+	}
+	return parts
+}
+
+// Store batches to database, retry while database is unreachable
+func (queue *ingestQueue) work(batches <-chan []journalEntry) {
+	defer queue.wg.Done()
+	for batch := range batches {
+		if queue.store(batch) {
+			queue.tracker.stored(batch)
+		}
+	}
+}
+
+// Store batch, returns false if the batch is left in journal because queue is stopping
+func (queue *ingestQueue) store(batch []journalEntry) bool {
+	backoff := time.Second
+	for {
+		err := storeEntries(queue.db, batch)
+		if err == nil {
+			return true
+		}
+		if databaseAvailable(queue.db) {
+			// data problem of some entries, store entries one by one so others are not blocked
+			logEvent("warning", "store batch failed, storing entries one by one", "size", len(batch), "error", err)
+			return queue.storeEach(batch)
+		}
+		logEvent("error", "database unavailable, retrying batch", "size", len(batch), "retry_in", backoff, "error", err)
+		if !queue.wait(backoff) {
+			return false
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (queue *ingestQueue) storeEach(batch []journalEntry) bool {
+	for _, entry := range batch {
+		backoff := time.Second
+		for {
+			err := storeEntries(queue.db, []journalEntry{entry})
+			if err == nil {
+				break
+			}
+			if databaseAvailable(queue.db) {
+				logEvent("error", "entry rejected", "seq", entry.Seq, "dev_id", entry.DeviceID, "request_code", entry.RequestCode, "error", err)
+				queue.reject(entry)
+				break
+			}
+			if !queue.wait(backoff) {
+				return false
+			}
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+		}
+	}
+	return true
+}
+
+// Keep rejected entry in separate file for investigation
+func (queue *ingestQueue) reject(entry journalEntry) {
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(queue.journal.dir, "rejected.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logEvent("error", "write rejected entry failed", "seq", entry.Seq, "error", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+func databaseAvailable(db *gorm.DB) bool {
+	sqlDB, err := db.DB()
+	return err == nil && sqlDB.Ping() == nil
+}
+
+// Advance journal checkpoint when all entries dispatched before are stored,
+// batches may be stored out of order by different workers
+type checkpointTracker struct {
+	journal *journal
+	mutex   sync.Mutex
+	order   []uint64
+	done    map[uint64]bool
+}
+
+func (tracker *checkpointTracker) dispatched(batch []journalEntry) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for _, entry := range batch {
+		tracker.order = append(tracker.order, entry.Seq)
+	}
+}
+
+func (tracker *checkpointTracker) stored(batch []journalEntry) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for _, entry := range batch {
+		tracker.done[entry.Seq] = true
+	}
+	var checkpoint uint64
+	advanced := false
+	for len(tracker.order) > 0 && tracker.done[tracker.order[0]] {
+		checkpoint = tracker.order[0]
+		delete(tracker.done, checkpoint)
+		tracker.order = tracker.order[1:]
+		advanced = true
+	}
+	if advanced {
+		if err := tracker.journal.saveCheckpoint(checkpoint); err != nil {
+			logEvent("error", "save journal checkpoint failed", "seq", checkpoint, "error", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIngestQueueSplitKeepsEntriesOfDeviceInOrder(t *testing.T) {
+	queue := &ingestQueue{workers: 3}
+	batch := []journalEntry{{Seq: 1, DeviceID: "A"}, {Seq: 2, DeviceID: "B"}, {Seq: 3, DeviceID: "A"}, {Seq: 4, DeviceID: "C"}, {Seq: 5, DeviceID: "B"}}
+	workers := make(map[string]int)
+	seqs := make(map[string][]uint64)
+	// a device is stored by the same worker in every batch
+	for i := 0; i < 2; i++ {
+		for worker, part := range queue.split(batch) {
+			for _, entry := range part {
+				if w, ok := workers[entry.DeviceID]; ok && w != worker {
+					t.Errorf("entries of device %s are split to workers %d and %d", entry.DeviceID, w, worker)
+				}
+				workers[entry.DeviceID] = worker
+				if i == 0 {
+					seqs[entry.DeviceID] = append(seqs[entry.DeviceID], entry.Seq)
+				}
+			}
+		}
+	}
+	want := map[string][]uint64{"A": {1, 3}, "B": {2, 5}, "C": {4}}
+	if !reflect.DeepEqual(seqs, want) {
+		t.Errorf("entries of devices = %v, want %v", seqs, want)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/masykur/absen"
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// Period to ignore enroll push of a user from a machine that just received the user by replication,
+// prevents the user from being replicated back and forth
+const replicationQuietPeriod = 5 * time.Minute
+
+var (
+	replicatedMutex sync.Mutex
+	replicated      = make(map[string]time.Time) // key: user id and target host
+)
+
+// Copy enrolled user to other machines, the machine sending the enroll data is excluded
+func replicateEnroll(sourceHost string, enroll EnrollJson) {
+	if len(replicateTo) == 0 || isReplicated(enroll.UserID, sourceHost) {
+		return
+	}
+	targets := make([]string, 0, len(replicateTo))
+	for _, target := range replicateTo {
+		address := absen.AddressWithPort(target, replicatePort)
+		if targetHost, _, _ := net.SplitHostPort(address); targetHost == sourceHost {
+			continue
+		}
+		targets = append(targets, address)
+	}
+	if len(targets) == 0 {
+		return
+	}
+	user := models.User{
+		UserID:       enroll.UserID,
+		Name:         enroll.Name,
+		Privilage:    enroll.Privilage,
+		Photo:        enroll.Photo,
+		Card:         enroll.Card,
+		Fingerprints: enroll.Fingerprints,
+		Face:         enroll.Face,
+		Password:     enroll.Password,
+		ValidStart:   enroll.ValidStart,
+		ValidEnd:     enroll.ValidEnd,
+		TimeGroups:   models.TimeGroups(enroll.TimeGroups)}
+	for _, result := range sf3500.ReplicateUsers([]models.User{user}, targets, 20*time.Second) {
+		if result.Success {
+			targetHost, _, _ := net.SplitHostPort(result.Address)
+			markReplicated(enroll.UserID, targetHost)
+			log.Printf("user %s replicated from %s to %s\n", enroll.UserID, sourceHost, result.Address)
+		} else {
+			log.Printf("replicate user %s from %s to %s failed: %s\n", enroll.UserID, sourceHost, result.Address, result.Error)
+		}
+	}
+}
+
+func markReplicated(userId string, host string) {
+	replicatedMutex.Lock()
+	defer replicatedMutex.Unlock()
+	now := time.Now()
+	for key, t := range replicated {
+		if now.Sub(t) > replicationQuietPeriod {
+			delete(replicated, key)
+		}
+	}
+	replicated[userId+"|"+host] = now
+}
+
+func isReplicated(userId string, host string) bool {
+	replicatedMutex.Lock()
+	defer replicatedMutex.Unlock()
+	t, ok := replicated[userId+"|"+host]
+	return ok && time.Since(t) <= replicationQuietPeriod
+}
@@ -1,286 +1,163 @@
 package cmd
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"strconv"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
-	"gorm.io/driver/sqlserver"
-	"gorm.io/gorm"
 )
 
-type Device struct {
-	ID        string `gorm:"size:20;primaryKey"`
-	Name      string `gorm:"size:255"`
-	Model     string `gorm:"size:255"`
-	Mode      string `gorm:"size:10"` // push or pull
-	IsOnline  bool
-	IPAddress string `gorm:"size:16"`
-	Port      uint16
-	Password  string `gorm:"size:255"`
-	Logs      []Log
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
-}
-
-type User struct {
-	ID           uint   `gorm:"primaryKey;autoIncrement:false"`
-	Number       string `gorm:"size:20"`
-	Name         string `gorm:"size:200;not null"`
-	Privilage    uint16 `gorm:"not null"`
-	Photo        string
-	Card         string `gorm:"size:10;index"`
-	Fingerprints string
-	Face         string
-	Password     string `gorm:"size:255"`
-	ValidStart   string `gorm:"size:8"`
-	ValidEnd     string `gorm:"size:8"`
-	TimeGroups   string
-	Logs         []Log
-	ActivatedAt  sql.NullTime
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
-}
-
-// type UserTimezone struct {
-// 	UserID    uint   `gorm:"primaryKey"`
-// 	Day       string `gorm:"primaryKey"`
-// 	Number    uint16 `gorm:"primaryKey"`
-// 	TimeStart time.Time
-// 	TimeEnd   time.Time
-// 	CreatedAt time.Time
-// 	UpdatedAt time.Time
-// }
-
-type Log struct {
-	ID         uint
-	DeviceID   string    `gorm:"size:20"`
-	UserID     uint      `gorm:"not null"`
-	Time       time.Time `gorm:"not null"`
-	VerifyMode string    `gorm:"size:20"`
-	IoMode     uint16    `gorm:"size:20"`
-	InOut      string    `gorm:"size:5;not null"`
-	DoorMode   string    `gorm:"size:20"`
-	LogPhoto   LogPhoto
-	CreatedAt  time.Time
-}
-
-type LogPhoto struct {
-	gorm.Model
-	LogID     uint
-	Photo     string
-	CreatedAt time.Time
-}
-
-type LogJson struct {
-	UserID     string `json:"userId"`
-	Time       string `json:"time"`
-	VerifyMode string `json:"verifyMode"`
-	IoMode     uint16 `json:"ioMode"`
-	InOut      string `json:"inOut"`
-	DoorMode   string `json:"doorMode"`
-	LogPhoto   string `json:"logPhoto"`
-}
-
-type EnrollJson struct {
-	UserID       string   `json:"userId"`
-	UserNumber   string   `json:"userNo"`
-	Name         string   `json:"name"`
-	Privilage    int      `json:"privilege"`
-	Photo        string   `json:"photo"`
-	Card         string   `json:"card"`
-	Fingerprints []string `json:"fps"`
-	Face         string   `json:"face"`
-	Password     string   `json:"pwd"`
-	ValidStart   string   `json:"vaildStart"`
-	ValidEnd     string   `json:"vaildEnd"`
-	TimeGroups   TimeGroups
-}
-
-type TimeGroups struct {
-	Sunday   []string `json:"sun"`
-	Monday   []string `json:"mon"`
-	Tuesday  []string `json:"tue"`
-	Wedneday []string `json:"wed"`
-	Thursday []string `json:"thu"`
-	Friday   []string `json:"fri"`
-	Saturday []string `json:"sat"`
-}
-
 var serverCommand = &cobra.Command{
 	Use:   "server",
 	Short: "Run http server",
 	Long:  `this command runs server and it won't turn off till you manually do it'`,
 	Run:   runServer}
 
+var (
+	dbMaxOpenConns  int
+	dbMaxIdleConns  int
+	shutdownTimeout time.Duration
+	journalDir      string
+	ingestWorkers   int
+	batchSize       int
+	batchWait       time.Duration
+	authMode        string
+	tlsCertFile     string
+	tlsKeyFile      string
+	apiToken        string
+	offlineAfter    time.Duration
+)
+
 func init() {
+	serverCommand.Flags().IntVar(&dbMaxOpenConns, "db-max-open-conns", 25, "Maximum number of open database connections")
+	serverCommand.Flags().IntVar(&dbMaxIdleConns, "db-max-idle-conns", 10, "Maximum number of idle database connections")
+	serverCommand.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Time to wait for in-flight requests when shutting down")
+	serverCommand.Flags().StringVar(&journalDir, "journal-dir", "", "Acknowledge requests after written to journal in this directory and store them to database in background")
+	serverCommand.Flags().IntVar(&ingestWorkers, "workers", 4, "Number of workers storing journal entries to database, entries of a device are stored by the same worker")
+	serverCommand.Flags().IntVar(&batchSize, "batch-size", 100, "Maximum number of journal entries stored in one batch")
+	serverCommand.Flags().DurationVar(&batchWait, "batch-wait", 500*time.Millisecond, "Maximum time to wait for a batch to be filled")
+	serverCommand.Flags().StringVar(&authMode, "auth-mode", authQuarantine, "How unknown devices are handled, open registers them, allowlist rejects them and quarantine holds them until approved")
+	serverCommand.Flags().StringVar(&tlsCertFile, "tls-cert", "", "Certificate file, the server listens with TLS when certificate and key are given")
+	serverCommand.Flags().StringVar(&tlsKeyFile, "tls-key", "", "Private key file of the certificate")
+	serverCommand.Flags().StringVar(&apiToken, "api-token", "", "Bearer token required by query API under /api/, empty to allow anyone to read and nobody to queue commands")
+	serverCommand.Flags().DurationVar(&offlineAfter, "offline-after", 10*time.Minute, "Mark device offline when it has not contacted the server within this duration")
 	RootCmd.AddCommand(serverCommand)
 }
 
 func runServer(cmd *cobra.Command, args []string) {
-	db, err := gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
+	db, err := openDatabase(dsn)
 	if err != nil {
-		panic("failed to connect database")
+		log.Fatalln("failed to connect database:", err)
 	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalln("failed to connect database:", err)
+	}
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(dbMaxOpenConns)
+	sqlDB.SetMaxIdleConns(dbMaxIdleConns)
+	sqlDB.SetConnMaxIdleTime(5 * time.Minute)
 
 	// Migrate the schema
-	db.AutoMigrate(&Device{})
-	db.AutoMigrate(&User{})
-	db.AutoMigrate(&Log{})
-	db.AutoMigrate(&LogPhoto{})
-
-	serverAddress := fmt.Sprintf("%v:%v", host, port)
-	fmt.Println("Keico SF3500 http server")
-	fmt.Println("Created by Ahmad Masykur 2022")
-	fmt.Printf("Starting server, listening on \"%v\"\n", serverAddress)
-
-	http.HandleFunc("/", handleRequest)
-	http.ListenAndServe(serverAddress, nil)
-	// return nil
-}
+	if err := migrateDatabase(db); err != nil {
+		log.Fatalln("failed to migrate database:", err)
+	}
 
-func handleRequest(writer http.ResponseWriter, request *http.Request) {
-	if body, err := io.ReadAll(request.Body); err == nil {
-		bodyData := []byte(body)
-		db, err := gorm.Open(sqlserver.Open(dsn), &gorm.Config{})
+	auth, err := newDeviceAuth(db, authMode)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		log.Fatalln("both --tls-cert and --tls-key are required to listen with TLS")
+	}
+	handler := &pushHandler{db: db, auth: auth}
+	if journalDir != "" {
+		queue, err := newIngestQueue(db, journalDir, ingestWorkers, batchSize, batchWait)
 		if err != nil {
-			panic("failed to connect database")
+			log.Fatalln("failed to open journal:", err)
 		}
-		deviceId := request.Header.Get("dev_id")
-		requestCode := request.Header.Get("request_code")
+		// entries not stored before last shutdown are replayed first
+		queue.start()
+		handler.queue = queue
+	}
 
-		var device Device
-		result := db.First(&device, "id = ?", deviceId)
-		addresses := strings.Split(request.RemoteAddr, ":")
-		model := request.Header.Get("dev_model")
-		device = Device{ID: deviceId, Model: model, IPAddress: addresses[0], Port: port, IsOnline: true, Mode: "push"}
-		if result.RowsAffected == 0 {
-			if trx := db.Create(&device); trx.Error != nil {
-				log.Fatalln("error", trx.Error)
-				writer.WriteHeader(http.StatusInternalServerError)
-				return
-			}
+	serverAddress := fmt.Sprintf("%v:%v", host, port)
+	fmt.Println("Keico SF3500 http server")
+	fmt.Println("Created by Ahmad Masykur 2022")
+	if tlsCertFile != "" {
+		fmt.Printf("Starting server, listening with TLS on \"%v\"\n", serverAddress)
+	} else {
+		fmt.Printf("Starting server, listening on \"%v\"\n", serverAddress)
+	}
+	logEvent("info", "device authorization", "mode", authMode)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.Handle("/api/", newAPIHandler(db, apiToken))
+	server := &http.Server{
+		Addr:              serverAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 30 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second}
+
+	// stop accepting new requests on SIGINT or SIGTERM and wait for in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go monitorDevices(ctx, db)
+	serverError := make(chan error, 1)
+	go func() {
+		if tlsCertFile != "" {
+			serverError <- server.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
 		} else {
-			if device.Mode != model || device.IPAddress != addresses[0] || device.Port != port || !device.IsOnline || device.Mode != "push" {
-				if trx := db.Model(&device).Updates(Device{Model: model, IPAddress: addresses[0], IsOnline: true, Mode: "push"}); trx.Error != nil {
-					log.Fatalln("error", trx.Error)
-					writer.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-			}
+			serverError <- server.ListenAndServe()
 		}
-
-		switch requestCode {
-		case "realtime_enroll_data":
-			var enroll EnrollJson
-			if err = json.Unmarshal(bodyData, &enroll); err == nil {
-				if userId, err := strconv.Atoi(enroll.UserID); err == nil {
-					var user User
-					var fingerprints string
-					var timeGroups string
-					if fps, err := json.Marshal(enroll.Fingerprints); err == nil && len(fps) > 0 {
-						fingerprints = string(fps)
-					}
-					if tgrs, err := json.Marshal(enroll.Fingerprints); err == nil && len(tgrs) > 0 {
-						timeGroups = string(tgrs)
-					}
-					result := db.First(&user, userId)
-					if result.RowsAffected == 0 {
-						user = User{ID: uint(userId),
-							Number:       enroll.UserNumber,
-							Name:         enroll.Name,
-							Privilage:    uint16(enroll.Privilage),
-							Photo:        enroll.Photo,
-							Card:         enroll.Card,
-							Fingerprints: fingerprints,
-							Face:         enroll.Face,
-							Password:     enroll.Password,
-							ValidStart:   enroll.ValidStart,
-							ValidEnd:     enroll.ValidEnd,
-							TimeGroups:   timeGroups}
-						if trx := db.Create(&user); trx.Error != nil {
-							log.Fatalln("error", trx.Error)
-							writer.WriteHeader(http.StatusInternalServerError)
-							return
-						}
-					} else {
-						if trx := db.Model(&user).Updates(User{
-							Number:       enroll.UserNumber,
-							Name:         enroll.Name,
-							Privilage:    uint16(enroll.Privilage),
-							Photo:        enroll.Photo,
-							Card:         enroll.Card,
-							Fingerprints: fingerprints,
-							Face:         enroll.Face,
-							Password:     enroll.Password,
-							ValidStart:   enroll.ValidStart,
-							ValidEnd:     enroll.ValidEnd,
-							TimeGroups:   timeGroups}); trx.Error != nil {
-							log.Fatalln("error", trx.Error)
-							writer.WriteHeader(http.StatusInternalServerError)
-							return
-						}
-					}
-				} else {
-					log.Fatalln("error", err)
-					writer.WriteHeader(http.StatusInternalServerError)
-					return
-				}
-			} else {
-				log.Fatalln("error", err)
-				writer.WriteHeader(http.StatusInternalServerError)
-				return
+	}()
+	select {
+	case err := <-serverError:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logEvent("error", "server stopped", "error", err)
+			if handler.queue != nil {
+				handler.queue.close(0)
 			}
-		case "realtime_glog":
-			var glog LogJson
-			if err = json.Unmarshal(bodyData, &glog); err == nil {
-				var logRecord Log
-				var logPhoto LogPhoto
-				if datetime, err := time.ParseInLocation("20060102150405", glog.Time, time.Local); err == nil {
-					logRecord.Time = datetime
-					if userId, err := strconv.Atoi(glog.UserID); err == nil {
-						var user User
-						result := db.First(&user, userId)
-						if result.RowsAffected == 0 {
-							user = User{ID: uint(userId), Name: strconv.Itoa(userId), Privilage: 0}
-							if trx := db.Create(&user); trx.Error != nil {
-								log.Fatalln("error", trx.Error)
-								writer.WriteHeader(http.StatusInternalServerError)
-								return
-							}
-						}
+			sqlDB.Close()
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		logEvent("info", "shutting down server", "timeout", shutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logEvent("error", "shutdown server failed", "error", err)
+		}
+		if handler.queue != nil {
+			// store remaining journal entries, the rest are replayed on next start
+			handler.queue.close(shutdownTimeout)
+		}
+	}
+	logEvent("info", "server stopped")
+}
 
-						logRecord = Log{Time: datetime, UserID: uint(userId), DeviceID: deviceId, DoorMode: glog.DoorMode, InOut: glog.InOut, IoMode: glog.IoMode, VerifyMode: glog.VerifyMode}
-						if trx := db.Create(&logRecord); trx.Error != nil {
-							log.Fatalln("error", trx.Error)
-							writer.WriteHeader(http.StatusInternalServerError)
-							return
-						}
-						logPhoto = LogPhoto{LogID: logRecord.ID, Photo: glog.LogPhoto}
-						if trx := db.Create(&logPhoto); trx.Error != nil {
-							log.Fatalln("error", trx.Error)
-							writer.WriteHeader(http.StatusInternalServerError)
-							return
-						}
-					}
-				}
-			} else {
-				log.Fatalln("error", err)
-				writer.WriteHeader(http.StatusInternalServerError)
-				return
-			}
+// Write structured log line in key=value format, fields are pairs of key and value
+func logEvent(level string, message string, fields ...interface{}) {
+	line := fmt.Sprintf("level=%s msg=%q", level, message)
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch value := fields[i+1].(type) {
+		case string:
+			line += fmt.Sprintf(" %v=%q", fields[i], value)
+		case error:
+			line += fmt.Sprintf(" %v=%q", fields[i], value.Error())
+		default:
+			line += fmt.Sprintf(" %v=%v", fields[i], value)
 		}
 	}
-	writer.Header()["response_code"] = []string{"OK"}
-	writer.Header()["trans_id"] = []string{"100"}
-	writer.WriteHeader(http.StatusOK)
+	log.Println(line)
 }
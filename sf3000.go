@@ -0,0 +1,203 @@
+package absen
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3000"
+)
+
+// SF3000 adapter, implements Clock, LogSource and UserStore.
+// The machine can't delete users, DeleteUsers returns ErrNotSupported.
+type Sf3000Device struct {
+	dev *sf3000.Sf3000
+	id  string
+}
+
+const sf3000Port = 5005
+
+func init() {
+	Register(Driver{
+		Model:       "sf3000",
+		Description: "Keico SF3000 fingerprint terminal",
+		DefaultPort: sf3000Port,
+		Params:      []Param{ParamMachineID, ParamPassword},
+		Open: func(options Options) (Device, error) {
+			device, err := OpenSf3000(options)
+			if err != nil {
+				return nil, err
+			}
+			return device, nil
+		}})
+}
+
+var (
+	_ Clock      = (*Sf3000Device)(nil)
+	_ LogSource  = (*Sf3000Device)(nil)
+	_ UserReader = (*Sf3000Device)(nil)
+)
+
+// Connect to SF3000 machine, default port is 5005
+func OpenSf3000(options Options) (*Sf3000Device, error) {
+	dev := new(sf3000.Sf3000)
+	if ok, err := dev.Connect(AddressWithPort(options.Address, sf3000Port), options.MachineID, options.Password, options.timeout()); !ok {
+		return nil, err
+	}
+	return &Sf3000Device{dev: dev, id: options.DeviceID}, nil
+}
+
+// Underlying driver, for features not covered by the common interfaces
+func (d *Sf3000Device) Driver() *sf3000.Sf3000 {
+	return d.dev
+}
+
+func (d *Sf3000Device) Model() string {
+	return "sf3000"
+}
+
+// Device id from options, or serial number read from machine on first call
+func (d *Sf3000Device) ID() string {
+	if d.id == "" {
+		if serialNumber, err := d.dev.GetSerialNumber(); err == nil {
+			d.id = serialNumber
+		}
+	}
+	return d.id
+}
+
+func (d *Sf3000Device) Capabilities() Capability {
+	return CapClock | CapLogs | CapUsers | CapFingerprint | CapCard
+}
+
+func (d *Sf3000Device) Close() error {
+	d.dev.Close()
+	return nil
+}
+
+func (d *Sf3000Device) Time() (time.Time, error) {
+	return d.dev.GetDateTime()
+}
+
+func (d *Sf3000Device) SetTime(t time.Time) error {
+	ok, err := d.dev.SetDateTime(t)
+	return DriverError(ok, err, "set time")
+}
+
+// Read all logs kept by machine, logs are not removed
+func (d *Sf3000Device) Punches() ([]Punch, error) {
+	_, logs, err := d.dev.FetchAllLogs()
+	if err != nil {
+		return nil, err
+	}
+	punches := make([]Punch, 0, len(logs))
+	deviceId := d.ID()
+	for _, logData := range logs {
+		if logData.UserID < 0 {
+			continue
+		}
+		credential := CredentialUnknown
+		switch {
+		case logData.SensorType&sf3000.Fingerprint != 0:
+			credential = CredentialFingerprint
+		case logData.SensorType&sf3000.Card != 0:
+			credential = CredentialCard
+		case logData.SensorType&sf3000.Keypad != 0:
+			credential = CredentialPassword
+		}
+		punches = append(punches, Punch{
+			DeviceID:   deviceId,
+			SubjectID:  strconv.Itoa(int(logData.UserID)),
+			Credential: credential,
+			Timestamp:  logData.DateTime,
+			Direction:  DirectionUnknown,
+			Result:     ResultGranted,
+			Raw:        logData})
+	}
+	return punches, nil
+}
+
+// Read user list, fingerprint templates are not included, read them with GetEnrollData of the driver
+func (d *Sf3000Device) Users() ([]User, error) {
+	list, err := d.dev.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(list))
+	for _, user := range list {
+		users = append(users, User{
+			ID:        strconv.Itoa(user.Id),
+			Privilege: int(user.Level),
+			Card:      formatCard(user.CardFacilityCode, user.CardId),
+			Raw:       user})
+	}
+	return users, nil
+}
+
+// Read users with their card and fingerprint templates
+func (d *Sf3000Device) UsersByID(ids ...string) ([]User, error) {
+	users := make([]User, 0, len(ids))
+	for _, id := range ids {
+		userId, err := strconv.Atoi(id)
+		if err != nil {
+			return users, fmt.Errorf("user id of SF3000 must be a number: %q", id)
+		}
+		user, err := d.dev.GetEnrollData(userId)
+		if err != nil {
+			return users, err
+		}
+		fingerprints := make([]string, 0, 2)
+		for _, template := range [][]byte{user.Fingerprint1, user.Fingerprint2} {
+			if len(template) > 0 {
+				fingerprints = append(fingerprints, base64.StdEncoding.EncodeToString(template))
+			}
+		}
+		users = append(users, User{
+			ID:           id,
+			Card:         formatCard(user.CardFacilityCode, user.CardId),
+			Fingerprints: fingerprints,
+			Raw:          user})
+	}
+	return users, nil
+}
+
+// Enroll users with card and up to 2 fingerprint templates
+func (d *Sf3000Device) SetUsers(users ...User) error {
+	if err := checkUserIds(users); err != nil {
+		return err
+	}
+	for _, user := range users {
+		item, _ := user.Raw.(sf3000.User)
+		id, err := strconv.Atoi(user.ID)
+		if err != nil {
+			return fmt.Errorf("user id of SF3000 must be a number: %q", user.ID)
+		}
+		item.Id = id
+		item.Level = sf3000.Level(user.Privilege)
+		item.CardFacilityCode, item.CardId = 0, 0
+		if user.Card != "" {
+			if item.CardFacilityCode, item.CardId, err = parseCard(user.Card); err != nil {
+				return err
+			}
+		}
+		if len(user.Fingerprints) > 2 {
+			return fmt.Errorf("SF3000 keeps up to 2 fingerprints of user %s", user.ID)
+		}
+		templates := make([][]byte, 2)
+		for i, fingerprint := range user.Fingerprints {
+			if templates[i], err = base64.StdEncoding.DecodeString(fingerprint); err != nil {
+				return fmt.Errorf("invalid fingerprint of user %s: %v", user.ID, err)
+			}
+		}
+		item.Fingerprint1, item.Fingerprint2 = templates[0], templates[1]
+		if ok, err := d.dev.SetEnrollData(item); !ok || err != nil {
+			return DriverError(ok, err, "set user "+user.ID)
+		}
+	}
+	return nil
+}
+
+func (d *Sf3000Device) DeleteUsers(ids ...string) error {
+	return ErrNotSupported
+}
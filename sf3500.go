@@ -0,0 +1,190 @@
+package absen
+
+import (
+	"time"
+
+	"github.com/masykur/absen/pkg/sf3500"
+	"github.com/masykur/absen/pkg/sf3500/models"
+)
+
+// SF3500 adapter, implements Clock, LogSource and UserStore
+type Sf3500Device struct {
+	dev *sf3500.Sf3500
+	id  string
+}
+
+const sf3500Port = 5005
+
+func init() {
+	Register(Driver{
+		Model:       "sf3500",
+		Description: "Keico SF3500 face and fingerprint terminal",
+		DefaultPort: sf3500Port,
+		Params:      []Param{ParamProtocolKey},
+		Open: func(options Options) (Device, error) {
+			device, err := OpenSf3500(options)
+			if err != nil {
+				return nil, err
+			}
+			return device, nil
+		}})
+}
+
+var (
+	_ Clock            = (*Sf3500Device)(nil)
+	_ MarkingLogSource = (*Sf3500Device)(nil)
+	_ UserStore        = (*Sf3500Device)(nil)
+)
+
+// Connect to SF3500 machine, default port is 5005
+func OpenSf3500(options Options) (*Sf3500Device, error) {
+	dev := new(sf3500.Sf3500)
+	dev.SetProtocolKey(options.ProtocolKey)
+	if ok, err := dev.Connect(AddressWithPort(options.Address, sf3500Port), options.timeout()); !ok {
+		return nil, err
+	}
+	return &Sf3500Device{dev: dev, id: options.DeviceID}, nil
+}
+
+// Underlying driver, for features not covered by the common interfaces
+func (d *Sf3500Device) Driver() *sf3500.Sf3500 {
+	return d.dev
+}
+
+func (d *Sf3500Device) Model() string {
+	return "sf3500"
+}
+
+// Device id from options, or device id read from machine on first call
+func (d *Sf3500Device) ID() string {
+	if d.id == "" {
+		if info, err := d.dev.GetDeviceInfo(); err == nil {
+			d.id = info.DeviceID
+		}
+	}
+	return d.id
+}
+
+func (d *Sf3500Device) Capabilities() Capability {
+	return CapClock | CapLogs | CapUsers | CapDeleteUsers | CapFingerprint | CapCard | CapFace | CapPalm | CapPassword | CapPhoto | CapDoor
+}
+
+func (d *Sf3500Device) Close() error {
+	d.dev.Close()
+	return nil
+}
+
+func (d *Sf3500Device) Time() (time.Time, error) {
+	return d.dev.GetDateTime()
+}
+
+func (d *Sf3500Device) SetTime(t time.Time) error {
+	ok, err := d.dev.SetDateTime(t)
+	return DriverError(ok, err, "set time")
+}
+
+// Read unread logs, they are not marked read. Use PunchesAndMark to mark them read after they are stored.
+func (d *Sf3500Device) Punches() ([]Punch, error) {
+	return d.FetchPunches(sf3500.LogFilter{NewOnly: true}, false)
+}
+
+// Read unread logs, they are marked read after handled
+func (d *Sf3500Device) PunchesAndMark(handle func(punches []Punch) error) (int, error) {
+	return d.FetchPunchesAndMark(sf3500.LogFilter{}, handle)
+}
+
+// Read logs matching the filter, see FetchLog of the driver
+func (d *Sf3500Device) FetchPunches(filter sf3500.LogFilter, markRead bool) ([]Punch, error) {
+	logs, err := d.dev.FetchLog(filter, markRead)
+	return d.punches(logs), err
+}
+
+// Pass every package of logs matching the filter to handle, logs are not marked read, see StreamLogs of the driver
+func (d *Sf3500Device) StreamPunches(filter sf3500.LogFilter, handle func(punches []Punch) error) error {
+	return d.dev.StreamLogs(filter, func(logs []models.LogData) error {
+		return handle(d.punches(logs))
+	})
+}
+
+// Read unread logs matching the filter, see FetchLogAndMark of the driver
+func (d *Sf3500Device) FetchPunchesAndMark(filter sf3500.LogFilter, handle func(punches []Punch) error) (int, error) {
+	return d.dev.FetchLogAndMark(filter, func(logs []models.LogData) error {
+		return handle(d.punches(logs))
+	})
+}
+
+func (d *Sf3500Device) punches(logs []models.LogData) []Punch {
+	punches := make([]Punch, 0, len(logs))
+	deviceId := d.ID()
+	for _, logData := range logs {
+		// log time is wall clock time of the machine
+		t := logData.Time.Time()
+		punches = append(punches, Punch{
+			DeviceID:   deviceId,
+			SubjectID:  logData.UserID,
+			Credential: parseCredential(logData.VerifyMode),
+			Timestamp:  time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local),
+			Direction:  parseDirection(logData.InOut),
+			Result:     ResultGranted,
+			Raw:        logData})
+	}
+	return punches
+}
+
+func (d *Sf3500Device) Users() ([]User, error) {
+	list, err := d.dev.AllUsers()
+	return d.users(list), err
+}
+
+// Pass every package of registered users to handle, see StreamUsers of the driver
+func (d *Sf3500Device) StreamUsers(handle func(users []User) error) error {
+	return d.dev.StreamUsers(func(list []models.User) error {
+		return handle(d.users(list))
+	})
+}
+
+func (d *Sf3500Device) UsersByID(ids ...string) ([]User, error) {
+	list, err := d.dev.UsersInfo(ids...)
+	return d.users(list), err
+}
+
+func (d *Sf3500Device) users(list []models.User) []User {
+	users := make([]User, 0, len(list))
+	for _, user := range list {
+		users = append(users, User{
+			ID:           user.UserID,
+			Name:         user.Name,
+			Privilege:    user.Privilage,
+			Card:         user.Card,
+			Password:     user.Password,
+			Fingerprints: user.Fingerprints,
+			Face:         user.Face,
+			Raw:          user})
+	}
+	return users
+}
+
+func (d *Sf3500Device) SetUsers(users ...User) error {
+	if err := checkUserIds(users); err != nil {
+		return err
+	}
+	list := make([]models.User, 0, len(users))
+	for _, user := range users {
+		item, _ := user.Raw.(models.User)
+		item.UserID = user.ID
+		item.Name = user.Name
+		item.Privilage = user.Privilege
+		item.Card = user.Card
+		item.Password = user.Password
+		item.Fingerprints = user.Fingerprints
+		item.Face = user.Face
+		list = append(list, item)
+	}
+	ok, err := d.dev.SetUserInfo(list...)
+	return DriverError(ok, err, "set users")
+}
+
+func (d *Sf3500Device) DeleteUsers(ids ...string) error {
+	ok, err := d.dev.DeleteUser(ids...)
+	return DriverError(ok, err, "delete users")
+}
@@ -0,0 +1,51 @@
+package absen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enrolled user normalized from machine user.
+// Templates are base64 encoded in the format of the machine, they can't be copied between different models.
+type User struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Privilege    int         `json:"privilege"` // 0 is normal user
+	Card         string      `json:"card"`
+	Password     string      `json:"password"`
+	Fingerprints []string    `json:"fingerprints"`
+	Face         string      `json:"face"`
+	Raw          interface{} `json:"-"` // user of the driver, fields not normalized are kept when the user is set back
+}
+
+// Card number formatted as 3 digits facility code followed by 5 digits card number
+func formatCard(facilityCode uint8, cardId uint16) string {
+	if facilityCode == 0 && cardId == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%03d%05d", facilityCode, cardId)
+}
+
+// Parse card number formatted by formatCard, number without facility code is accepted
+func parseCard(card string) (uint8, uint16, error) {
+	number, err := strconv.Atoi(card)
+	if err != nil || number < 0 {
+		return 0, 0, fmt.Errorf("invalid card number %q", card)
+	}
+	facilityCode, cardId := number/100000, number%100000
+	if facilityCode > 255 || cardId > 65535 {
+		return 0, 0, fmt.Errorf("invalid card number %q", card)
+	}
+	return uint8(facilityCode), uint16(cardId), nil
+}
+
+// Check users to set have ids, an empty id is usually a user in json format of the machine read as normalized user
+func checkUserIds(users []User) error {
+	for i, user := range users {
+		if strings.TrimSpace(user.ID) == "" {
+			return fmt.Errorf("id of user %d is empty", i+1)
+		}
+	}
+	return nil
+}